@@ -146,7 +146,12 @@ export declare type CredentialsProvider = () => Credentials;
  * Options for creating a new MQTT client.
  */
 export declare interface ClientOptions extends HasTags {
-  /** Client identifier (must be unique per broker connection). */
+  /**
+   * Client identifier (must be unique per broker connection).
+   * The `{scenario}`, `{vu}` and `{iter}` placeholders are replaced with the scenario name,
+   * VU number and iteration number when connecting, and `{rand:N}` with N random letters and digits.
+   * `{scenario}` is replaced with an empty string outside of a scenario, and other placeholders are kept as they are.
+   */
   client_id?: string;
   /** The username required by your broker, if any. */
   username?: string;
@@ -156,6 +161,102 @@ export declare interface ClientOptions extends HasTags {
   credentials_provider?: CredentialsProvider;
   /** Last Will and Testament message. */
   will?: Will;
+  /**
+   * MQTT protocol version: `3` (MQTT 3.1), `4` (MQTT 3.1.1) or `5` (MQTT 5.0).
+   * If omitted, MQTT 3.1.1 is used with a fallback to MQTT 3.1.
+   */
+  protocol_version?: 3 | 4 | 5;
+  /**
+   * Stamp published messages with the send time and report the end-to-end latency
+   * of received stamped messages in the `mqtt_message_latency` metric (default: false).
+   * Must be enabled on both the publishing and the receiving client.
+   */
+  measure_latency?: boolean;
+  /**
+   * Stamp published messages with a per topic sequence number and report lost, duplicated and
+   * out of order messages received with sequence numbers in the `mqtt_messages_lost`,
+   * `mqtt_messages_duplicated` and `mqtt_messages_out_of_order` metrics (default: false).
+   * Must be enabled on both the publishing and the receiving client.
+   */
+  track_sequence?: boolean;
+  /** TLS settings of the client, overriding the k6 TLS configuration. */
+  tls?: TLSOptions;
+  /**
+   * Buffer received messages in a bounded queue until the event loop handles them,
+   * so slow message handlers do not stall the connection.
+   */
+  inbound_queue?: InboundQueueOptions;
+  /**
+   * Format of the payload passed to message listeners, `waitForMessage()` and `messages()` (default: `ArrayBuffer`).
+   * Messages with a payload that cannot be decoded fire the `error` event instead.
+   */
+  payload_format?: PayloadFormat;
+  /**
+   * Limit the rate of published messages with a token bucket.
+   * The time publishes wait for the limiter is recorded in the `mqtt_publish_throttled_duration` metric.
+   * Synchronous publishes wait on the VU event loop, blocking its timers and event handlers meanwhile.
+   */
+  rate?: RateOptions;
+  /**
+   * Where in-flight QoS 1 and QoS 2 messages of the session are kept (default: in memory).
+   * With a file store they survive `end()` and are sent again by a new connection with `clean_session: false`.
+   */
+  store?: StoreOptions;
+}
+
+/**
+ * Session store of a client.
+ */
+export declare interface StoreOptions {
+  /** `memory` (default) or `file`. */
+  type?: "memory" | "file";
+  /** Directory of the file store. Each client ID uses its own subdirectory. */
+  dir?: string;
+}
+
+/**
+ * Rate limit of published messages.
+ */
+export declare interface RateOptions {
+  /** Number of messages published per second. */
+  messages_per_second: number;
+  /** Number of messages that can be published at once, without waiting (default: 1) */
+  burst?: number;
+}
+
+/**
+ * Options of the queue buffering received messages.
+ */
+export declare interface InboundQueueOptions {
+  /** Maximum number of queued messages (default: 100) */
+  size?: number;
+  /**
+   * What happens when a message arrives while the queue is full:
+   * `block` waits until the queue has room, stalling the connection (default),
+   * `drop_oldest` drops the oldest queued message and `drop_newest` drops the arriving message.
+   * Dropped messages are counted in the `mqtt_messages_dropped` metric.
+   */
+  overflow?: "block" | "drop_oldest" | "drop_newest";
+}
+
+/**
+ * TLS settings of a single client.
+ */
+export declare interface TLSOptions {
+  /** PEM encoded CA certificate(s) used to verify the broker certificate. */
+  ca?: string;
+  /** PEM encoded client certificate. */
+  cert?: string;
+  /** PEM encoded private key of the client certificate. */
+  key?: string;
+  /** Server name used to verify the broker certificate and sent as SNI. */
+  server_name?: string;
+  /** Skip verifying the broker certificate (default: false). */
+  insecure_skip_verify?: boolean;
+  /** Minimum TLS version. */
+  min_version?: "tls1.0" | "tls1.1" | "tls1.2" | "tls1.3";
+  /** Application protocols offered during the TLS handshake (ALPN). */
+  alpn?: string[];
 }
 
 /**
@@ -166,10 +267,33 @@ export declare interface ConnectOptions extends HasTags {
   keepalive?: number;
   /** Connection timeout in milliseconds (default: 30000) */
   connect_timeout?: number;
-  /** By setting this flag, you are indicating that no messages saved by the broker for this client should be delivered. */
+  /**
+   * By setting this flag, you are indicating that no messages saved by the broker for this client should be delivered (default: true).
+   * Set to `false` to resume the previous session of the client ID.
+   */
   clean_session?: boolean;
   /** Array of broker URLs to connect to (for failover) */
   servers?: string[];
+  /** Automatically reconnect when the connection is lost (default: true). */
+  auto_reconnect?: boolean;
+  /** Maximum delay between reconnect attempts in milliseconds, doubling from 1 second (default: 600000). */
+  max_reconnect_interval?: number;
+  /** Keep retrying the initial connection until it succeeds (default: false). */
+  connect_retry?: boolean;
+  /** Delay between initial connection attempts in milliseconds (default: 30000). */
+  connect_retry_interval?: number;
+  /** Subscribe again to all active subscriptions after an automatic reconnect (default: false). */
+  resume_subs?: boolean;
+}
+
+/**
+ * Acknowledgement of a connection by the broker (CONNACK packet).
+ */
+export declare interface Connack {
+  /** Whether the broker resumed an existing session, see `clean_session`. */
+  session_present: boolean;
+  /** CONNACK return code, or reason code with MQTT v5 (0 on success). */
+  return_code: number;
 }
 
 /**
@@ -183,8 +307,28 @@ export declare interface EndOptions extends HasTags { }
 export declare interface SubscribeOptions extends HasTags {
   /** Quality of Service level for the subscription. */
   qos?: QoS;
+  /**
+   * Callback receiving the messages matching the subscribed topic filter(s)
+   * instead of the `message` event listener.
+   */
+  handler?: MessageListener;
+  /** Format of the payload of messages received by the subscription, overriding the client option. */
+  payload_format?: PayloadFormat;
 }
 
+/**
+ * Format of received payloads passed to message listeners:
+ * `binary` passes a `Uint8Array`, `string` a UTF-8 decoded string and `json` the parsed JSON value.
+ * Without a format, the payload is passed as an `ArrayBuffer`.
+ */
+export declare type PayloadFormat = "binary" | "string" | "json";
+
+/**
+ * Listener receiving a message: its topic, payload and packet details.
+ * The type of the payload depends on the payload format, `ArrayBuffer` by default.
+ */
+export declare type MessageListener = (topic: string, payload: any, packet: MessagePacket) => void;
+
 /**
  * Type alias for accepting either a single string or an array of strings.
  * Used for topics and other string-based parameters.
@@ -204,6 +348,124 @@ export declare interface PublishOptions extends HasTags {
   qos?: QoS;
   /** Whether the message should be retained by the broker (default: false) */
   retain?: boolean;
+  /** Set to `json` to serialize any payload, including strings and numbers, to JSON. */
+  encoding?: PayloadEncoding;
+  /** MQTT v5 message properties (requires `protocol_version: 5`). */
+  properties?: MessageProperties;
+}
+
+/**
+ * Message published with `publishMany()`.
+ */
+export declare interface BatchMessage extends Omit<PublishOptions, "tags"> {
+  /** The topic to publish to. */
+  topic: string;
+  /** The message payload. */
+  payload: PublishPayload;
+}
+
+/**
+ * Result of a batch published with `publishMany()`.
+ */
+export declare interface PublishManyResult {
+  /** The result of every message, in the order of the batch. */
+  results: { topic: string; error: string | null }[];
+  /** Time from sending the first message until all messages were acknowledged, in milliseconds. */
+  duration: number;
+}
+
+/**
+ * MQTT v5 message properties.
+ */
+export declare interface MessageProperties {
+  /** User properties as key-value pairs. */
+  user?: Record<string, string>;
+  /** Topic name for a response message. */
+  response_topic?: string;
+  /** Correlation data used by the sender of a request to identify the response. */
+  correlation_data?: string;
+}
+
+/**
+ * Options for sending a request message.
+ */
+export declare interface RequestOptions extends HasTags {
+  /** Quality of Service level for the request and the reply subscription (default: 0) */
+  qos?: QoS;
+  /** Time to wait for the reply in milliseconds (default: 30000) */
+  timeout?: number;
+  /** Set to `json` to serialize any payload, including strings and numbers, to JSON. */
+  encoding?: PayloadEncoding;
+}
+
+/**
+ * Options for waiting for a message.
+ */
+export declare interface WaitOptions extends HasTags {
+  /** Time to wait for a matching message in milliseconds (default: 30000) */
+  timeout?: number;
+  /** Format of the payload of the message, overriding the client option. */
+  payload_format?: PayloadFormat;
+}
+
+/**
+ * Options for iterating over received messages.
+ */
+export declare interface MessagesOptions extends HasTags {
+  /** Maximum number of buffered messages (default: 100) */
+  buffer?: number;
+  /**
+   * What happens when a message arrives while the buffer is full:
+   * `drop_oldest` drops the oldest buffered message (default), `drop_newest` drops the arriving message,
+   * and `error` stops the iterator, so the next `next()` call rejects with an `MQTTError`
+   * once the buffered messages are consumed. The error is passed to the `error` listeners as well.
+   * Dropped messages are counted in the `mqtt_messages_dropped` metric.
+   */
+  overflow?: "drop_oldest" | "drop_newest" | "error";
+  /** Format of the payload of the messages, overriding the client option. */
+  payload_format?: PayloadFormat;
+}
+
+/**
+ * Async iterator over received messages.
+ *
+ * `for await` loops are not supported by the k6 runtime unless the script is transpiled, so call `next()` in a loop.
+ */
+export declare interface MessageIterator extends AsyncIterableIterator<Message> {
+  /** Waits for the next message. */
+  next(): Promise<IteratorResult<Message, undefined>>;
+  /** Stops the iterator, dropping the buffered messages. */
+  return(): Promise<IteratorResult<Message, undefined>>;
+}
+
+/**
+ * A received message.
+ */
+export declare interface Message {
+  /** Topic the message was published to. */
+  topic: string;
+  /** Message payload, an `ArrayBuffer` unless a payload format is set. */
+  payload: any;
+  /** Details of the received packet. */
+  packet: MessagePacket;
+}
+
+/**
+ * Details of a received message, passed to the `message` event listener.
+ */
+export declare interface MessagePacket {
+  /** QoS level the message was delivered with, which may be lower than the QoS it was published with. */
+  qos: QoS;
+  /** Whether the message was delivered from the retained messages of the broker. */
+  retain: boolean;
+  /** Whether the message is a redelivery of an earlier attempt. */
+  dup: boolean;
+  /** Packet identifier of the message (0 for QoS 0 messages). */
+  message_id: number;
+  /** Time the message was received, in milliseconds since the Unix epoch. */
+  received_at: number;
+  /** MQTT v5 message properties, if the message carries any. */
+  properties?: MessageProperties;
 }
 
 /**
@@ -212,6 +474,18 @@ export declare interface PublishOptions extends HasTags {
  */
 export declare type StringOrArrayBuffer = string | ArrayBuffer;
 
+/**
+ * Payload of a published message.
+ * Strings are UTF-8 encoded, TypedArrays and DataViews are sent without copying,
+ * and plain objects and arrays are serialized to JSON.
+ */
+export declare type PublishPayload = StringOrArrayBuffer | ArrayBufferView | object;
+
+/**
+ * Encoding of a published payload.
+ */
+export declare type PayloadEncoding = "json";
+
 /**
  * MQTT client for connecting to brokers and managing MQTT operations.
  *
@@ -298,11 +572,21 @@ export declare class Client {
    * Connects to an MQTT broker.
    * @param url Broker URL or connection options.
    * @param options Optional connection options.
+   * @returns The CONNACK of the broker, or undefined if the error was passed to an `error` listener.
    */
-  connect(url: string | ConnectOptions, options?: ConnectOptions): void;
+  connect(url: string | ConnectOptions, options?: ConnectOptions): Connack | undefined;
+
+  /**
+   * Connects to an MQTT broker asynchronously.
+   * @param url Broker URL or connection options.
+   * @param options Optional connection options.
+   * @returns Promise that resolves with the CONNACK of the broker.
+   */
+  connectAsync(url: string | ConnectOptions, options?: ConnectOptions): Promise<Connack | undefined>;
 
   /**
    * Disconnects from the MQTT broker synchronously.
+   * The client may connect again afterwards, keeping its event listeners.
    * @param options - Optional disconnect options.
    */
   end(options?: EndOptions): void;
@@ -322,6 +606,8 @@ export declare class Client {
 
   /**
    * Subscribe to one or more topics synchronously.
+   *
+   * Shared subscriptions can be created using the `$share/<group>/<filter>` topic filter syntax.
    * @param topic Topic(s) or subscription options.
    * @param options Optional subscription options.
    */
@@ -356,7 +642,7 @@ export declare class Client {
    * @param payload - The message payload (string or ArrayBuffer).
    * @param options - Optional publish options.
    */
-  publish(topic: string, payload: StringOrArrayBuffer, options?: PublishOptions): void;
+  publish(topic: string, payload: PublishPayload, options?: PublishOptions): void;
 
   /**
    * Publishes a message to an MQTT topic asynchronously.
@@ -365,13 +651,73 @@ export declare class Client {
    * @param options - Optional publish options.
    * @returns Promise that resolves when publish is complete.
    */
-  publishAsync(topic: string, payload: StringOrArrayBuffer, options?: PublishOptions): Promise<void>;
+  publishAsync(topic: string, payload: PublishPayload, options?: PublishOptions): Promise<void>;
+
+  /**
+   * Publishes a batch of messages, sending all of them before waiting for their acknowledgements.
+   *
+   * Failed messages do not throw, their error is reported in the result and passed to the `error` listeners instead.
+   * The time until all messages are acknowledged is recorded in the `mqtt_publish_batch_duration` metric.
+   * @param messages - The messages to publish.
+   * @param options - Optional batch options.
+   * @returns The result of every message and the duration of the batch.
+   */
+  publishMany(messages: BatchMessage[], options?: HasTags): PublishManyResult;
+
+  /**
+   * Publishes a batch of messages asynchronously, sending all of them before waiting for their acknowledgements.
+   * @param messages - The messages to publish.
+   * @param options - Optional batch options.
+   * @returns Promise that resolves with the result of every message and the duration of the batch.
+   */
+  publishManyAsync(messages: BatchMessage[], options?: HasTags): Promise<PublishManyResult>;
+
+  /**
+   * Sends a request message and waits for the matching reply.
+   *
+   * The reply is expected on a per-client reply topic. With MQTT v5 the request carries
+   * the `response_topic` and `correlation_data` properties, and the reply must carry the same
+   * `correlation_data`. With MQTT 3.1.1 the request payload is wrapped in a JSON envelope
+   * (`{"response_topic": "...", "correlation_data": "...", "payload": "<base64>"}`),
+   * and the reply must be an envelope with the same `correlation_data`.
+   *
+   * The time between sending the request and receiving the reply is recorded in the `mqtt_request_duration` metric.
+   * @param topic - The topic to send the request to.
+   * @param payload - The request payload (string or ArrayBuffer).
+   * @param options - Optional request options.
+   * @returns Promise that resolves with the reply payload.
+   */
+  request(topic: string, payload: PublishPayload, options?: RequestOptions): Promise<ArrayBuffer>;
+
+  /**
+   * Waits for the next message whose topic matches the topic filter.
+   *
+   * Only messages of existing subscriptions are received, so subscribe to the topic first.
+   * The message is passed to the `message` event listeners as well.
+   * @param filter - Topic filter, may contain the `+` and `#` wildcards.
+   * @param options - Optional wait options.
+   * @returns Promise that resolves with the message, or rejects with an `MQTTError` on timeout.
+   * The timeout is passed to the `error` listeners as well, and the promise rejects even if there are some.
+   */
+  waitForMessage(filter: string, options?: WaitOptions): Promise<Message>;
+
+  /**
+   * Returns an async iterator over the received messages whose topic matches the topic filter.
+   *
+   * Messages are buffered from the moment the iterator is created until they are taken with `next()`.
+   * The iterator ends when `return()` is called or the client ends.
+   * @param filter - Optional topic filter, may contain the `+` and `#` wildcards (default: all messages).
+   * @param options - Optional buffering options.
+   */
+  messages(filter?: string, options?: MessagesOptions): MessageIterator;
 
   /**
    * Listen for the `connect` event.
+   *
+   * With MQTT 3.1.1 the listener receives no CONNACK after an automatic reconnect.
    * @param listener Callback for connect event.
    */
-  on(event: "connect", listener: () => void): void;
+  on(event: "connect", listener: (connack?: Connack) => void): void;
 
   /**
    * Listen for the `end` event.
@@ -385,17 +731,142 @@ export declare class Client {
    */
   on(event: "reconnect", listener: () => void): void;
 
+  /**
+   * Listen for the `offline` event, fired when the connection to the broker is lost unexpectedly.
+   * @param listener Callback for offline event.
+   */
+  on(event: "offline", listener: (event: ConnectionLostEvent) => void): void;
+
+  /**
+   * Listen for the `close` event, fired when the connection to the broker is lost
+   * and the client does not reconnect automatically (`auto_reconnect: false`).
+   * @param listener Callback for close event.
+   */
+  on(event: "close", listener: (event: ConnectionLostEvent) => void): void;
+
   /**
    * Listen for incoming messages.
    * @param listener Callback for message event.
    */
-  on(event: "message", listener: (topic: string, payload: ArrayBuffer) => void): void;
+  on(event: "message", listener: MessageListener): void;
 
   /**
    * Listen for errors.
    * @param listener Callback for error event.
    */
   on(event: "error", listener: (error: MQTTError) => void): void;
+
+  /**
+   * Listen for an event only once: the listener is removed before it is called.
+   * Accepts the same events and listeners as `on()`.
+   * @param event Event name.
+   * @param listener Callback for the event.
+   */
+  once(event: ClientEvent, listener: (...args: any[]) => void): void;
+
+  /**
+   * Remove a listener registered with `on()` or `once()`.
+   * @param event Event name.
+   * @param listener The listener to remove.
+   */
+  off(event: ClientEvent, listener: (...args: any[]) => void): void;
+
+  /**
+   * Remove all listeners of an event, or of all events if no event is given.
+   * @param event Optional event name.
+   */
+  removeAllListeners(event?: ClientEvent): void;
+}
+
+/**
+ * Options for creating a pool of MQTT clients.
+ *
+ * The `{index}` placeholder in `client_id`, `username` and `password` is replaced
+ * with the index of each client in the pool, starting at 0. The other `client_id` placeholders
+ * are replaced as well, while `username` and `password` only support `{index}`.
+ */
+export declare interface ClientPoolOptions extends ClientOptions {
+  /** Number of clients in the pool. */
+  size: number;
+}
+
+/**
+ * Pool of MQTT clients opened from a single VU, sharing its event loop and metrics.
+ *
+ * @example
+ * ```ts
+ * import { ClientPool } from "k6/x/mqtt";
+ *
+ * export default async function () {
+ *   const pool = new ClientPool({ size: 100, client_id: "device-{index}" })
+ *
+ *   await pool.connectAsync(__ENV["MQTT_BROKER_ADDRESS"])
+ *
+ *   for (const [index, client] of pool.clients.entries()) {
+ *     await client.publishAsync(`devices/${index}/status`, "online")
+ *   }
+ *
+ *   await pool.endAsync()
+ * }
+ * ```
+ */
+export declare class ClientPool {
+  /** Number of clients in the pool. */
+  readonly size: number;
+  /** The clients of the pool. */
+  readonly clients: Client[];
+
+  /**
+   * Create a new pool of MQTT clients.
+   * @param options Pool and client options.
+   */
+  constructor(options: ClientPoolOptions);
+
+  /**
+   * Connects all clients one after the other.
+   * @param url Broker URL or connection options.
+   * @param options Optional connection options.
+   */
+  connect(url: string | ConnectOptions, options?: ConnectOptions): void;
+
+  /**
+   * Connects all clients concurrently.
+   * @param url Broker URL or connection options.
+   * @param options Optional connection options.
+   * @returns Promise that resolves when all clients are connected, or rejects with the first error.
+   */
+  connectAsync(url: string | ConnectOptions, options?: ConnectOptions): Promise<void>;
+
+  /**
+   * Disconnects all clients synchronously.
+   * @param options - Optional disconnect options.
+   */
+  end(options?: EndOptions): void;
+
+  /**
+   * Disconnects all clients asynchronously.
+   * @param options - Optional disconnect options.
+   * @returns Promise that resolves when all clients are disconnected.
+   */
+  endAsync(options?: EndOptions): Promise<void>;
+}
+
+/**
+ * Events emitted by the client.
+ */
+export declare type ClientEvent = "connect" | "end" | "reconnect" | "offline" | "close" | "message" | "error";
+
+/**
+ * Describes why the connection to the broker was lost.
+ */
+export declare interface ConnectionLostEvent {
+  /**
+   * Reason the connection was lost: `server_disconnect`, `keepalive_timeout`,
+   * `connection_closed`, `timeout`, `network_error` or `unknown`.
+   */
+  reason: string;
+  /** Error message describing the cause. */
+  message: string;
 }
 
 /**
@@ -1,16 +1,23 @@
 package mqtt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/grafana/sobek"
 	"github.com/sirupsen/logrus"
 	"go.k6.io/k6/v2/js/common"
 	"go.k6.io/k6/v2/js/modules"
+	"golang.org/x/time/rate"
 )
 
+var errUnsupportedProtocol = errors.New("unsupported protocol version")
+
 type will struct {
 	Topic   string
 	Payload string
@@ -29,9 +36,53 @@ type clientOptions struct {
 	Password            sobek.Value
 	CredentialsProvider sobek.Callable
 	Will                *will
+	ProtocolVersion     uint
+	MeasureLatency      bool
+	TrackSequence       bool
+	Tls                 *tlsOptions //nolint:revive
+	InboundQueue        *inboundQueueOptions
+	PayloadFormat       string
+	Rate                *rateOptions
+	Store               *storeOptions
 	Tags                map[string]string
 }
 
+func (co *clientOptions) validate() error {
+	switch co.ProtocolVersion {
+	case 0, protocolVersion31, protocolVersion311, protocolVersion5:
+	default:
+		return fmt.Errorf("%w: %d", errUnsupportedProtocol, co.ProtocolVersion)
+	}
+
+	if err := validatePayloadFormat(co.PayloadFormat); err != nil {
+		return err
+	}
+
+	if co.Store != nil {
+		if err := co.Store.validate(); err != nil {
+			return err
+		}
+	}
+
+	if co.Rate != nil {
+		if err := co.Rate.validate(); err != nil {
+			return err
+		}
+	}
+
+	if co.InboundQueue != nil {
+		if err := co.InboundQueue.validate(); err != nil {
+			return err
+		}
+	}
+
+	if co.Tls != nil {
+		return co.Tls.load()
+	}
+
+	return nil
+}
+
 func (co *clientOptions) toPaho(opts *paho.ClientOptions, runtime *sobek.Runtime) {
 	if sobek.IsString(co.ClientId) {
 		opts.SetClientID(co.ClientId.String())
@@ -53,6 +104,10 @@ func (co *clientOptions) toPaho(opts *paho.ClientOptions, runtime *sobek.Runtime
 	if co.Will != nil {
 		opts.SetWill(co.Will.Topic, co.Will.Payload, co.Will.Qos, co.Will.Retain)
 	}
+
+	if co.ProtocolVersion == protocolVersion31 || co.ProtocolVersion == protocolVersion311 {
+		opts.SetProtocolVersion(co.ProtocolVersion)
+	}
 }
 
 func (co *clientOptions) getCredentialsProvider(runtime *sobek.Runtime) paho.CredentialsProvider {
@@ -78,7 +133,7 @@ func (co *clientOptions) getCredentialsProvider(runtime *sobek.Runtime) paho.Cre
 }
 
 type client struct {
-	pahoClient paho.Client
+	pahoClient mqttClient
 
 	url string
 	log logrus.FieldLogger
@@ -86,12 +141,41 @@ type client struct {
 	clientOpts *clientOptions
 	connOpts   *connectOptions
 
-	handlers sync.Map
+	instanceID string
+
+	listeners   map[string][]*listener
+	listenersMu sync.Mutex
+
+	connTags atomic.Pointer[connectionTags]
+
+	shareGroups   sync.Map
+	subscriptions sync.Map
+	connected     atomic.Bool
+
+	publishSequences sync.Map
+	sequences        *sequenceTracker
+
+	replyTopic      string
+	replySubscribed atomic.Bool
+	requestSeq      atomic.Uint64
+	requests        sync.Map
+
+	waiters   sync.Map
+	waiterSeq atomic.Uint64
+
+	iterators   sync.Map
+	iteratorSeq atomic.Uint64
+
+	inbound *inboundQueue
+	limiter *rate.Limiter
+
+	clientID  string
+	clientIDs *clientIDRegistry
 
 	vu       modules.VU
 	callChan chan func() error
 	stop     chan struct{}
-	stopOnce sync.Once
+	loopMu   sync.Mutex
 
 	metrics *mqttMetrics
 
@@ -103,35 +187,111 @@ func newClient(log logrus.FieldLogger, vu modules.VU, metrics *mqttMetrics) *cli
 	c.log = log
 	c.vu = vu
 	c.callChan = make(chan func() error)
-	c.stop = make(chan struct{})
 	c.connOpts = new(connectOptions)
+	c.listeners = make(map[string][]*listener)
+	c.instanceID = newInstanceID()
+	c.replyTopic = replyTopicPrefix + c.instanceID
+	c.sequences = newSequenceTracker()
 
 	c.metrics = metrics
 
 	return c
 }
 
+// newInstanceID returns a random identifier distinguishing client instances across VUs.
+func newInstanceID() string {
+	id := make([]byte, 8) //nolint:mnd
+
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+// closedChan is returned by stopped while the event loop is not running.
+var closedChan = func() chan struct{} { //nolint:gochecknoglobals
+	ch := make(chan struct{})
+	close(ch)
+
+	return ch
+}()
+
+// startLoop starts the event loop, and the pump of the inbound queue if configured, unless they are running.
+// They are started again when connecting after the client ended.
+func (c *client) startLoop() {
+	c.loopMu.Lock()
+	defer c.loopMu.Unlock()
+
+	if c.stop != nil {
+		return
+	}
+
+	stop := make(chan struct{})
+	c.stop = stop
+
+	if c.inbound != nil {
+		go c.pumpInbound(stop)
+	}
+
+	go c.loop(stop)
+}
+
+// stopLoop stops the running event loop and pump of the inbound queue.
 func (c *client) stopLoop() {
-	c.stopOnce.Do(func() { close(c.stop) })
+	c.loopMu.Lock()
+	defer c.loopMu.Unlock()
+
+	c.closeStop(c.stop)
 }
 
-func (m *module) client(call sobek.ConstructorCall) *sobek.Object {
-	toValue := m.vu.Runtime().ToValue
+// closeStop closes stop if it belongs to the running event loop. It must be called with the loop lock held.
+func (c *client) closeStop(stop chan struct{}) {
+	if stop != nil && c.stop == stop {
+		close(stop)
+		c.stop = nil
+	}
+}
 
-	c := newClient(m.log, m.vu, m.metrics)
-	this := call.This
-	must := func(err error) {
-		if err != nil {
-			common.Throw(m.vu.Runtime(), err)
-		}
+// stopped returns a channel closed once the event loop stops, or already closed if it is not running.
+func (c *client) stopped() <-chan struct{} {
+	c.loopMu.Lock()
+	defer c.loopMu.Unlock()
+
+	if c.stop == nil {
+		return closedChan
 	}
 
-	c.clientOpts = new(clientOptions)
+	return c.stop
+}
+
+func (m *module) client(call sobek.ConstructorCall) *sobek.Object {
+	opts := new(clientOptions)
 
 	if len(call.Arguments) > 0 {
-		must(m.vu.Runtime().ExportTo(call.Arguments[0], &c.clientOpts))
+		m.must(m.vu.Runtime().ExportTo(call.Arguments[0], &opts))
 	}
 
+	m.must(opts.validate())
+
+	m.newClientObject(call.This, opts)
+
+	return nil
+}
+
+func (m *module) must(err error) {
+	if err != nil {
+		common.Throw(m.vu.Runtime(), err)
+	}
+}
+
+// newClientObject creates a client with validated options and exposes its methods on this.
+func (m *module) newClientObject(this *sobek.Object, opts *clientOptions) *client {
+	toValue := m.vu.Runtime().ToValue
+	must := m.must
+
+	c := newClient(m.log, m.vu, m.metrics)
+	c.clientOpts = opts
+	c.clientIDs = m.clientIDs
+
 	must(this.Set("connect", toValue(c.connect)))
 	must(this.Set("connectAsync", toValue(c.connectAsync)))
 	must(this.Set("end", toValue(c.end)))
@@ -140,15 +300,31 @@ func (m *module) client(call sobek.ConstructorCall) *sobek.Object {
 	must(this.Set("reconnectAsync", toValue(c.reconnectAsync)))
 	must(this.Set("publish", toValue(c.publish)))
 	must(this.Set("publishAsync", toValue(c.publishAsync)))
+	must(this.Set("publishMany", toValue(c.publishMany)))
+	must(this.Set("publishManyAsync", toValue(c.publishManyAsync)))
 	must(this.Set("subscribe", toValue(c.subscribe)))
 	must(this.Set("subscribeAsync", toValue(c.subscribeAsync)))
 	must(this.Set("unsubscribe", toValue(c.unsubscribe)))
 	must(this.Set("unsubscribeAsync", toValue(c.unsubscribeAsync)))
+	must(this.Set("request", toValue(c.request)))
+	must(this.Set("waitForMessage", toValue(c.waitForMessage)))
+	must(this.Set("messages", toValue(c.messages)))
 	must(this.Set("on", toValue(c.on)))
+	must(this.Set("once", toValue(c.once)))
+	must(this.Set("off", toValue(c.off)))
+	must(this.Set("removeAllListeners", toValue(c.removeAllListeners)))
 
 	must(this.DefineAccessorProperty("connected", toValue(c.isConnected), nil, sobek.FLAG_FALSE, sobek.FLAG_FALSE))
 
-	go c.loop()
+	if c.clientOpts.Rate != nil {
+		c.limiter = c.clientOpts.Rate.limiter()
+	}
 
-	return nil
+	if qo := c.clientOpts.InboundQueue; qo != nil {
+		c.inbound = newInboundQueue(qo)
+	}
+
+	c.startLoop()
+
+	return c
 }
@@ -69,3 +69,22 @@ func Test_clientOptions_toPaho_credentialsProvider(t *testing.T) {
 	require.Equal(t, "test-user", username)
 	require.Equal(t, "test-pass", password)
 }
+
+func Test_clientOptions_toPaho_protocolVersion(t *testing.T) {
+	t.Parallel()
+
+	for _, version := range []uint{protocolVersion31, protocolVersion311} {
+		pahoOpts := paho.NewClientOptions()
+
+		co := &clientOptions{ProtocolVersion: version}
+
+		require.NoError(t, co.validate())
+
+		co.toPaho(pahoOpts, sobek.New())
+
+		require.Equal(t, version, pahoOpts.ProtocolVersion)
+	}
+
+	require.NoError(t, (&clientOptions{ProtocolVersion: protocolVersion5}).validate())
+	require.ErrorIs(t, (&clientOptions{ProtocolVersion: 6}).validate(), errUnsupportedProtocol)
+}
@@ -1,6 +1,7 @@
 package mqtt
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/url"
@@ -19,11 +20,16 @@ var (
 )
 
 type connectOptions struct {
-	Keepalive      sobek.Value
-	ConnectTimeout sobek.Value
-	CleanSession   bool
-	Servers        []string
-	Tags           map[string]string
+	Keepalive            sobek.Value
+	ConnectTimeout       sobek.Value
+	CleanSession         *bool
+	Servers              []string
+	AutoReconnect        *bool
+	MaxReconnectInterval sobek.Value
+	ConnectRetry         bool
+	ConnectRetryInterval sobek.Value
+	ResumeSubs           bool
+	Tags                 map[string]string
 }
 
 func (co *connectOptions) toPaho(opts *paho.ClientOptions) {
@@ -35,22 +41,76 @@ func (co *connectOptions) toPaho(opts *paho.ClientOptions) {
 		opts.SetConnectTimeout(time.Millisecond * time.Duration(co.ConnectTimeout.ToInteger()))
 	}
 
-	if co.CleanSession {
-		opts.SetCleanSession(true)
+	if co.CleanSession != nil {
+		opts.SetCleanSession(*co.CleanSession)
 	}
 
 	for _, server := range co.Servers {
 		opts.AddBroker(server)
 	}
+
+	if co.AutoReconnect != nil {
+		opts.SetAutoReconnect(*co.AutoReconnect)
+	}
+
+	if sobek.IsNumber(co.MaxReconnectInterval) && co.MaxReconnectInterval.ToInteger() > 0 {
+		opts.SetMaxReconnectInterval(time.Millisecond * time.Duration(co.MaxReconnectInterval.ToInteger()))
+	}
+
+	if co.ConnectRetry {
+		opts.SetConnectRetry(true)
+	}
+
+	if sobek.IsNumber(co.ConnectRetryInterval) && co.ConnectRetryInterval.ToInteger() > 0 {
+		opts.SetConnectRetryInterval(time.Millisecond * time.Duration(co.ConnectRetryInterval.ToInteger()))
+	}
+}
+
+func (co *connectOptions) autoReconnect() bool {
+	return co.AutoReconnect == nil || *co.AutoReconnect
+}
+
+// connackToken is implemented by connect tokens carrying the CONNACK packet of the broker.
+type connackToken interface {
+	SessionPresent() bool
+	ReturnCode() byte
+}
+
+// connackReader is implemented by clients keeping the CONNACK packet of their latest connection.
+type connackReader interface {
+	lastConnack() *connack
 }
 
-func (c *client) connect(urlOrOpts sobek.Value, optsOrEmpty sobek.Value) error {
+// connack is the acknowledgement of a connection by the broker.
+type connack struct {
+	sessionPresent bool
+	returnCode     byte
+}
+
+func newConnack(token connackToken) *connack {
+	return &connack{sessionPresent: token.SessionPresent(), returnCode: token.ReturnCode()}
+}
+
+// export returns the connack as passed to scripts. The return code is the reason code with MQTT 5.
+func (ca *connack) export() map[string]any {
+	return map[string]any{
+		"session_present": ca.sessionPresent,
+		"return_code":     ca.returnCode,
+	}
+}
+
+func (c *client) connect(urlOrOpts sobek.Value, optsOrEmpty sobek.Value) (sobek.Value, error) {
 	err := c.connectPrepare(urlOrOpts, optsOrEmpty)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	ack, err := c.connectExecute()
+	if err != nil || ack == nil {
+		return sobek.Undefined(), err
 	}
 
-	return c.connectExecute()
+	return c.vu.Runtime().ToValue(ack.export()), nil
 }
 
 func (c *client) connectAsync(urlOrOpts sobek.Value, optsOrEmpty sobek.Value) (*sobek.Promise, error) {
@@ -62,13 +122,20 @@ func (c *client) connectAsync(urlOrOpts sobek.Value, optsOrEmpty sobek.Value) (*
 	promise, resolve, reject := promises.New(c.vu)
 
 	go func() {
-		if err := c.connectExecute(); err != nil {
+		ack, err := c.connectExecute()
+		if err != nil {
 			reject(err)
 
 			return
 		}
 
-		resolve(nil)
+		if ack == nil {
+			resolve(nil)
+
+			return
+		}
+
+		resolve(ack.export())
 	}()
 
 	return promise, nil
@@ -79,7 +146,9 @@ func (c *client) reconnect() error {
 
 	c.log.Debug("Reconnecting to MQTT broker")
 
-	return c.connectExecute()
+	_, err := c.connectExecute()
+
+	return err
 }
 
 func (c *client) reconnectAsync() (*sobek.Promise, error) {
@@ -163,25 +232,77 @@ func (c *client) validateAddress(urlStr string) error {
 	return err
 }
 
-func (c *client) connectExecute() error {
+// connectExecute connects to the broker and fires the connect event.
+// It returns the CONNACK of the broker, or nil if the error was passed to the error event.
+func (c *client) connectExecute() (*connack, error) {
+	// The event loop is stopped by end and disconnecting, so it is started again for the new connection.
+	c.startLoop()
+
+	ack, err := c.connectBroker()
+	if err != nil || ack == nil {
+		return ack, err
+	}
+
+	// The connect handler is called before the token completes, so the event of the first connection is fired here.
+	// It is fired after releasing the lock, and the CONNACK is converted on the event loop.
+	c.fire("connect", ack.export())
+
+	return ack, nil
+}
+
+// connectBroker creates the paho client and connects it to the broker.
+// It returns the CONNACK of the broker, or nil if the error was passed to the error event.
+func (c *client) connectBroker() (*connack, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.log.Debug("Connecting to MQTT broker")
 
-	c.pahoClient = c.newPahoClient()
+	c.snapshotTags()
 
-	if token := c.pahoClient.Connect(); token.Wait() && token.Error() != nil {
-		if err := c.handleError(token.Error(), "connect", c.connOpts.Tags, "url", c.url); err != nil {
-			return err
-		}
+	pahoClient, pending, err := c.newPahoClient()
+	if err != nil {
+		return nil, c.handleError(err, "connect", c.connOpts.Tags, "url", c.url)
+	}
 
-		return nil
+	c.pahoClient = pahoClient
+	options := c.pahoClient.OptionsReader()
+
+	c.snapshotTags()
+
+	c.trackClientID(options.ClientID())
+	c.replySubscribed.Store(false)
+	c.shareGroups.Clear()
+	c.subscriptions.Clear()
+	c.connected.Store(false)
+
+	start := time.Now()
+
+	token := c.pahoClient.Connect()
+	if token.Wait() && token.Error() != nil {
+		c.trackClientID("")
+
+		return nil, c.handleError(token.Error(), "connect", c.connOpts.Tags, "url", c.url)
 	}
 
+	// The protocol version is known once connected, as MQTT 3.1.1 falls back to 3.1.
+	c.snapshotTags()
+
+	c.addDurationMetrics(c.metrics.mqttConnectDuration, "connect", time.Since(start), nil)
 	c.addCallMetrics("connect", nil)
 
-	return nil
+	// Messages left in the store are sent again when the session is resumed.
+	if !options.CleanSession() {
+		c.addRedeliveredMetrics("sent", pending, c.tags())
+	}
+
+	ack := new(connack)
+
+	if t, ok := token.(connackToken); ok {
+		ack = newConnack(t)
+	}
+
+	return ack, nil
 }
 
 func (c *client) disconnect() {
@@ -199,13 +320,59 @@ func (c *client) disconnect() {
 	}
 
 	c.pahoClient = nil
+
+	c.snapshotTags()
+	c.trackClientID("")
+}
+
+// trackClientID registers the client ID of a new connection, releasing the ID of the previous one,
+// and warns if another connected client already uses the ID.
+func (c *client) trackClientID(id string) {
+	c.clientIDs.release(c.clientID)
+	c.clientID = id
+
+	if !c.clientIDs.acquire(id) {
+		c.log.WithField("client_id", id).
+			Warn("Client ID is already used by another connected client, the broker may disconnect one of them")
+	}
+}
+
+// tlsConfig returns the TLS settings of the k6 test overridden by the client TLS options.
+func (c *client) tlsConfig() *tls.Config {
+	conf := c.vu.State().TLSConfig
+
+	if conf == nil && c.clientOpts.Tls == nil {
+		return nil
+	}
+
+	var tlsConfig *tls.Config
+
+	if conf != nil {
+		tlsConfig = conf.Clone()
+	} else {
+		tlsConfig = new(tls.Config)
+	}
+
+	if strings.HasPrefix(c.url, "wss://") {
+		// Overriding the NextProtos to avoid talking http2
+		// @see https://github.com/grafana/xk6-mqtt/issues/20
+		tlsConfig.NextProtos = []string{"http/1.1"}
+	}
+
+	if c.clientOpts.Tls != nil {
+		c.clientOpts.Tls.apply(tlsConfig)
+	}
+
+	return tlsConfig
 }
 
-func (c *client) newPahoClient() paho.Client {
+func (c *client) newPahoClient() (mqttClient, int, error) {
 	opts := paho.NewClientOptions()
 
 	c.clientOpts.toPaho(opts, c.vu.Runtime())
 
+	opts.SetClientID(expandTemplate(opts.ClientID, c.templateVars()))
+
 	if len(c.url) != 0 {
 		opts.AddBroker(c.url)
 	}
@@ -215,18 +382,21 @@ func (c *client) newPahoClient() paho.Client {
 	opts.SetDefaultPublishHandler(c.messageHandler)
 	opts.SetOnConnectHandler(c.connectHandler)
 	opts.SetReconnectingHandler(c.reconnectHandler)
+	opts.SetConnectionLostHandler(c.connectionLostHandler)
 
-	if conf := c.vu.State().TLSConfig; conf != nil {
-		// Overriding the NextProtos to avoid talking http2
-		// @see https://github.com/grafana/xk6-mqtt/issues/20
-		tlsConfig := conf.Clone()
+	if tlsConfig := c.tlsConfig(); tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
 
-		if strings.HasPrefix(c.url, "wss://") {
-			tlsConfig.NextProtos = []string{"http/1.1"}
-		}
+	if c.clientOpts.ProtocolVersion == protocolVersion5 {
+		v5 := newPahoV5Client(opts)
 
-		opts.SetTLSConfig(tlsConfig)
+		pending, err := c.setupStore(opts, v5)
+
+		return v5, pending, err
 	}
 
-	return paho.NewClient(opts)
+	pending, err := c.setupStore(opts, nil)
+
+	return paho.NewClient(opts), pending, err
 }
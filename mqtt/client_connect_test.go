@@ -1,11 +1,16 @@
 package mqtt
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net"
 	"net/url"
 	"os"
 	"testing"
+	"time"
 
+	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/grafana/sobek"
 	"github.com/grafana/xk6-mqtt/internal/broker"
 	"github.com/stretchr/testify/require"
@@ -28,7 +33,7 @@ func TestClientConnect(t *testing.T) {
 
 	handlerCalled := false
 
-	client.on("connect", func(_ sobek.Value, _ ...sobek.Value) (sobek.Value, error) {
+	onEvent(t, client, "connect", func(_ sobek.Value, _ ...sobek.Value) (sobek.Value, error) {
 		require.NoError(t, client.end(nil))
 
 		handlerCalled = true
@@ -37,7 +42,8 @@ func TestClientConnect(t *testing.T) {
 	})
 
 	err := runtime.EventLoop.Start(func() error {
-		require.NoError(t, client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), nil)) //nolint:forbidigo // test reads the embedded broker address from env
+		_, err := client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), nil) //nolint:forbidigo // test reads the embedded broker address from env
+		require.NoError(t, err)
 
 		return nil
 	})
@@ -78,7 +84,7 @@ func TestClientConnectAuthenticated(t *testing.T) {
 
 	handlerCalled := false
 
-	client.on("connect", func(_ sobek.Value, _ ...sobek.Value) (sobek.Value, error) {
+	onEvent(t, client, "connect", func(_ sobek.Value, _ ...sobek.Value) (sobek.Value, error) {
 		require.NoError(t, client.end(nil))
 
 		handlerCalled = true
@@ -87,7 +93,8 @@ func TestClientConnectAuthenticated(t *testing.T) {
 	})
 
 	err := runtime.EventLoop.Start(func() error {
-		require.NoError(t, client.connect(toValue(addr), nil))
+		_, err := client.connect(toValue(addr), nil)
+		require.NoError(t, err)
 
 		return nil
 	})
@@ -128,7 +135,8 @@ func TestClientConnectBlacklisted(t *testing.T) {
 	toValue := runtime.VU.Runtime().ToValue
 
 	err = runtime.EventLoop.Start(func() error {
-		return client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), nil) //nolint:forbidigo // test reads the embedded broker address from env
+		_, err := client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), nil) //nolint:forbidigo // test reads the embedded broker address from env
+		return err
 	})
 
 	require.Error(t, err)
@@ -160,3 +168,270 @@ func TestClientReconnectWithoutConnect(t *testing.T) {
 
 	runtime.EventLoop.WaitOnRegistered()
 }
+
+func TestClientAutoReconnect(t *testing.T) {
+	t.Parallel()
+
+	for _, version := range []uint{protocolVersion311, protocolVersion5} {
+		t.Run(protocolName(version), func(t *testing.T) {
+			t.Parallel()
+
+			server := broker.New(true)
+
+			t.Cleanup(func() {
+				require.NoError(t, server.Close())
+			})
+
+			tcpListener, ok := server.Listeners.Get("tcp")
+
+			require.True(t, ok)
+
+			runtime := newTestRuntime(t)
+			mm := newMqttMetrics(runtime.VU)
+			logger := runtime.VU.InitEnv().Logger
+			state, samples := newTestVUStateWithSamples(t)
+
+			runtime.MoveToVUContext(state)
+
+			toValue := runtime.VU.Runtime().ToValue
+			clientID := "reconnect-" + protocolName(version)
+
+			client := newTestClient(t, logger, runtime.VU, mm)
+			client.clientOpts.ClientId = toValue(clientID)
+			client.clientOpts.ProtocolVersion = version
+
+			opts, err := runtime.VU.Runtime().RunString(`({auto_reconnect: true, max_reconnect_interval: 100, resume_subs: true})`)
+			require.NoError(t, err)
+
+			connects, received := 0, false
+
+			onEvent(t, client, "connect", func(_ sobek.Value, args ...sobek.Value) (sobek.Value, error) {
+				connects++
+
+				// The connack of automatic reconnects is only passed on with MQTT 5.
+				if connects == 1 || version == protocolVersion5 {
+					require.Len(t, args, 1)
+					require.Equal(t, int64(0), args[0].ToObject(runtime.VU.Runtime()).Get("return_code").ToInteger())
+				}
+
+				if connects == 1 {
+					require.NoError(t, client.subscribe(toValue("test/reconnect"), &subscribeOptions{Qos: 1}))
+
+					cl, ok := server.Clients.Get(clientID)
+					require.True(t, ok)
+
+					cl.Stop(errConnectionDown)
+
+					return sobek.Undefined(), nil
+				}
+
+				require.NoError(t, client.publish("test/reconnect", toValue("resumed"), &publishOptions{Qos: 1}))
+
+				return sobek.Undefined(), nil
+			})
+
+			onEvent(t, client, "message", func(_ sobek.Value, _ ...sobek.Value) (sobek.Value, error) {
+				received = true
+
+				require.NoError(t, client.end(nil))
+
+				return sobek.Undefined(), nil
+			})
+
+			err = runtime.EventLoop.Start(func() error {
+				_, err := client.connect(toValue("mqtt://"+tcpListener.Address()), opts)
+				return err
+			})
+
+			require.NoError(t, err)
+
+			runtime.EventLoop.WaitOnRegistered()
+
+			require.GreaterOrEqual(t, connects, 2)
+			require.True(t, received, "subscription was not resumed")
+			require.NotEmpty(t, collectSamples(samples, mm.mqttReconnects))
+		})
+	}
+}
+
+func Test_connectOptions_toPaho_reconnect(t *testing.T) {
+	t.Parallel()
+
+	runtime := sobek.New()
+	autoReconnect := false
+
+	co := &connectOptions{
+		AutoReconnect:        &autoReconnect,
+		MaxReconnectInterval: runtime.ToValue(5000),
+		ConnectRetry:         true,
+		ConnectRetryInterval: runtime.ToValue(250),
+		ResumeSubs:           true,
+	}
+
+	opts := paho.NewClientOptions()
+
+	co.toPaho(opts)
+
+	require.False(t, opts.AutoReconnect)
+	require.Equal(t, 5*time.Second, opts.MaxReconnectInterval)
+	require.True(t, opts.ConnectRetry)
+	require.Equal(t, 250*time.Millisecond, opts.ConnectRetryInterval)
+	// Subscriptions are resumed by the connect handler, so paho does not subscribe a second time.
+	require.False(t, opts.ResumeSubs)
+
+	opts = paho.NewClientOptions()
+
+	new(connectOptions).toPaho(opts)
+
+	require.True(t, opts.AutoReconnect)
+}
+
+func TestClientConnectionLost(t *testing.T) {
+	t.Parallel()
+
+	for _, version := range []uint{protocolVersion311, protocolVersion5} {
+		t.Run(protocolName(version), func(t *testing.T) {
+			t.Parallel()
+
+			server := broker.New(true)
+
+			t.Cleanup(func() {
+				require.NoError(t, server.Close())
+			})
+
+			tcpListener, ok := server.Listeners.Get("tcp")
+
+			require.True(t, ok)
+
+			runtime := newTestRuntime(t)
+			mm := newMqttMetrics(runtime.VU)
+			logger := runtime.VU.InitEnv().Logger
+			state, samples := newTestVUStateWithSamples(t)
+
+			runtime.MoveToVUContext(state)
+
+			toValue := runtime.VU.Runtime().ToValue
+			clientID := "lost-" + protocolName(version)
+
+			client := newTestClient(t, logger, runtime.VU, mm)
+			client.clientOpts.ClientId = toValue(clientID)
+			client.clientOpts.ProtocolVersion = version
+
+			opts, err := runtime.VU.Runtime().RunString(`({auto_reconnect: false})`)
+			require.NoError(t, err)
+
+			var events []string
+
+			onEvent(t, client, "connect", func(_ sobek.Value, _ ...sobek.Value) (sobek.Value, error) {
+				cl, ok := server.Clients.Get(clientID)
+				require.True(t, ok)
+
+				cl.Stop(errConnectionDown)
+
+				return sobek.Undefined(), nil
+			})
+
+			onEvent(t, client, "offline", func(_ sobek.Value, args ...sobek.Value) (sobek.Value, error) {
+				events = append(events, "offline:"+args[0].ToObject(runtime.VU.Runtime()).Get("reason").String())
+
+				return sobek.Undefined(), nil
+			})
+
+			onEvent(t, client, "close", func(_ sobek.Value, args ...sobek.Value) (sobek.Value, error) {
+				events = append(events, "close:"+args[0].ToObject(runtime.VU.Runtime()).Get("reason").String())
+
+				require.NoError(t, client.end(nil))
+
+				return sobek.Undefined(), nil
+			})
+
+			err = runtime.EventLoop.Start(func() error {
+				_, err := client.connect(toValue("mqtt://"+tcpListener.Address()), opts)
+				return err
+			})
+
+			require.NoError(t, err)
+
+			runtime.EventLoop.WaitOnRegistered()
+
+			require.Equal(t, []string{"offline:connection_closed", "close:connection_closed"}, events)
+
+			lost := collectSamples(samples, mm.mqttConnectionLost)
+
+			require.Len(t, lost, 1)
+
+			reason, _ := lost[0].Tags.Get("reason")
+
+			require.Equal(t, "connection_closed", reason)
+		})
+	}
+}
+
+func Test_connectionLostReason(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]error{
+		"server_disconnect": fmt.Errorf("%w (reason code: 142)", errServerDisconnect),
+		"keepalive_timeout": errors.New("pingresp not received, disconnecting"),
+		"connection_closed": fmt.Errorf("read: %w", io.EOF),
+		"timeout":           &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded},
+		"network_error":     &net.OpError{Op: "dial", Err: errors.New("no route to host")},
+		"unknown":           errors.New("boom"),
+	}
+
+	for want, err := range tests {
+		require.Equal(t, want, connectionLostReason(err), err.Error())
+	}
+}
+
+func TestClientConnectProtocolTag(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		version uint
+		want    string
+	}{
+		{version: 0, want: "MQTT/3.1.1"},
+		{version: protocolVersion31, want: "MQTT/3.1"},
+		{version: protocolVersion311, want: "MQTT/3.1.1"},
+		{version: protocolVersion5, want: "MQTT/5.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			t.Parallel()
+
+			runtime := newTestRuntime(t)
+			mm := newMqttMetrics(runtime.VU)
+			logger := runtime.VU.InitEnv().Logger
+			state, samples := newTestVUStateWithSamples(t)
+
+			runtime.MoveToVUContext(state)
+
+			client := newTestClient(t, logger, runtime.VU, mm)
+			client.clientOpts.ProtocolVersion = tt.version
+
+			toValue := runtime.VU.Runtime().ToValue
+
+			err := runtime.EventLoop.Start(func() error {
+				_, err := client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), nil) //nolint:forbidigo // test reads the embedded broker address from env
+				require.NoError(t, err)
+				require.NoError(t, client.end(nil))
+
+				return nil
+			})
+
+			require.NoError(t, err)
+
+			runtime.EventLoop.WaitOnRegistered()
+
+			// The tag shows the version actually used, known once connected.
+			durations := collectSamples(samples, mm.mqttConnectDuration)
+
+			require.Len(t, durations, 1)
+
+			proto, _ := durations[0].Tags.Get("proto")
+			require.Equal(t, tt.want, proto)
+		})
+	}
+}
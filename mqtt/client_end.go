@@ -21,6 +21,8 @@ func (c *client) end(opts *endOptions) error {
 	c.addCallMetrics("end", opts.Tags)
 
 	c.disconnect()
+	c.flushSequences()
+	c.closeIterators()
 	c.stopLoop()
 
 	return nil
@@ -0,0 +1,332 @@
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/v2/js/promises"
+	"go.k6.io/k6/v2/metrics"
+)
+
+const defaultIteratorBuffer = 100
+
+const (
+	overflowDropOldest = "drop_oldest"
+	overflowDropNewest = "drop_newest"
+	overflowError      = "error"
+)
+
+var (
+	errInvalidOverflow  = errors.New("invalid overflow policy")
+	errIteratorOverflow = errors.New("message iterator buffer overflow")
+)
+
+type messagesOptions struct {
+	Buffer        int
+	Overflow      string
+	PayloadFormat string
+	Tags          map[string]string
+}
+
+func (mo *messagesOptions) validate() error {
+	if mo.Buffer <= 0 {
+		mo.Buffer = defaultIteratorBuffer
+	}
+
+	if err := validatePayloadFormat(mo.PayloadFormat); err != nil {
+		return err
+	}
+
+	switch mo.Overflow {
+	case "":
+		mo.Overflow = overflowDropOldest
+	case overflowDropOldest, overflowDropNewest, overflowError:
+	default:
+		return fmt.Errorf("%w: %q", errInvalidOverflow, mo.Overflow)
+	}
+
+	return nil
+}
+
+// messageIterator buffers the messages matching a topic filter until they are taken by next().
+type messageIterator struct {
+	filter string
+	opts   *messagesOptions
+
+	queue      []*receivedMessage
+	notify     chan struct{}
+	closed     bool
+	overflowed bool
+
+	mu sync.Mutex
+}
+
+func newMessageIterator(filter string, opts *messagesOptions) *messageIterator {
+	return &messageIterator{filter: filter, opts: opts, notify: make(chan struct{})}
+}
+
+func (it *messageIterator) matches(topic string) bool {
+	return len(it.filter) == 0 || topicMatches(it.filter, topic)
+}
+
+// push adds the message to the buffer, applying the overflow policy when it is full.
+// It returns the dropped message, if any.
+func (it *messageIterator) push(msg *receivedMessage) *receivedMessage {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if it.closed {
+		return nil
+	}
+
+	var dropped *receivedMessage
+
+	if len(it.queue) >= it.opts.Buffer {
+		switch it.opts.Overflow {
+		case overflowDropNewest:
+			return msg
+		case overflowError:
+			it.overflowed = true
+			it.closed = true
+			it.broadcast()
+
+			return msg
+		default:
+			dropped = it.queue[0]
+			it.queue = it.queue[1:]
+		}
+	}
+
+	it.queue = append(it.queue, msg)
+	it.broadcast()
+
+	return dropped
+}
+
+func (it *messageIterator) close() {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if !it.closed {
+		it.closed = true
+		it.broadcast()
+	}
+}
+
+// broadcast wakes up all pending take calls. It must be called with the lock held.
+func (it *messageIterator) broadcast() {
+	close(it.notify)
+	it.notify = make(chan struct{})
+}
+
+// take waits for the next message. It returns nil when the iterator is closed.
+func (it *messageIterator) take(done <-chan struct{}) (*receivedMessage, error) {
+	for {
+		it.mu.Lock()
+
+		if len(it.queue) != 0 {
+			msg := it.queue[0]
+			it.queue = it.queue[1:]
+			it.mu.Unlock()
+
+			return msg, nil
+		}
+
+		if it.closed {
+			overflowed := it.overflowed
+			it.mu.Unlock()
+
+			if overflowed {
+				return nil, errIteratorOverflow
+			}
+
+			return nil, nil
+		}
+
+		notify := it.notify
+		it.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-done:
+			return nil, nil
+		}
+	}
+}
+
+func (c *client) messages(filter sobek.Value, opts *messagesOptions) (*sobek.Object, error) {
+	rt := c.vu.Runtime()
+
+	if opts == nil {
+		opts = new(messagesOptions)
+	}
+
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	var topicFilter string
+
+	if filter != nil && !sobek.IsUndefined(filter) && !sobek.IsNull(filter) {
+		topicFilter = filter.String()
+
+		if err := validateFilter(topicFilter); err != nil {
+			return nil, err
+		}
+	}
+
+	format := c.payloadFormat(opts.PayloadFormat)
+
+	it := newMessageIterator(topicFilter, opts)
+	id := c.iteratorSeq.Add(1)
+
+	c.iterators.Store(id, it)
+
+	obj := rt.NewObject()
+
+	next := func() *sobek.Promise {
+		promise, resolve, reject := c.newPromise()
+
+		go func() {
+			msg, err := it.take(c.vu.Context().Done())
+			if err != nil {
+				c.iterators.Delete(id)
+
+				// Like waitForMessage, the promise is rejected even if an error listener was called.
+				wrapped, _ := c.reportError(err, "messages", opts.Tags, "topic", topicFilter)
+
+				reject(wrapped)
+
+				return
+			}
+
+			if msg == nil {
+				c.iterators.Delete(id)
+
+				resolve(func(*sobek.Runtime) (any, error) {
+					return map[string]any{"done": true, "value": sobek.Undefined()}, nil
+				})
+
+				return
+			}
+
+			export, err := msg.exporter(format)
+			if err != nil {
+				wrapped, _ := c.reportError(err, "messages", opts.Tags, "topic", msg.topic)
+
+				reject(wrapped)
+
+				return
+			}
+
+			resolve(func(rt *sobek.Runtime) (any, error) {
+				value, err := export(rt)
+				if err != nil {
+					return nil, err
+				}
+
+				return map[string]any{"done": false, "value": value}, nil
+			})
+		}()
+
+		return promise
+	}
+
+	// return is called when a for await loop of a transpiled script exits early.
+	stop := func() *sobek.Promise {
+		promise, resolve, _ := promises.New(c.vu)
+
+		it.close()
+		c.iterators.Delete(id)
+
+		resolve(map[string]any{"done": true, "value": sobek.Undefined()})
+
+		return promise
+	}
+
+	if err := obj.Set("next", next); err != nil {
+		return nil, err
+	}
+
+	if err := obj.Set("return", stop); err != nil {
+		return nil, err
+	}
+
+	if err := setAsyncIterator(rt, obj); err != nil {
+		return nil, err
+	}
+
+	return obj, nil
+}
+
+// setAsyncIterator makes obj async iterable. The runtime lacks a native Symbol.asyncIterator,
+// so the well-known registry symbol used by transpiled for await loops is set as well.
+func setAsyncIterator(rt *sobek.Runtime, obj *sobek.Object) error {
+	self := rt.ToValue(func(call sobek.FunctionCall) sobek.Value { return call.This })
+
+	symbol := rt.Get("Symbol").ToObject(rt)
+
+	symbolFor, ok := sobek.AssertFunction(symbol.Get("for"))
+	if !ok {
+		return fmt.Errorf("%w: Symbol.for is not a function", errInvalidType)
+	}
+
+	registered, err := symbolFor(sobek.Undefined(), rt.ToValue("Symbol.asyncIterator"))
+	if err != nil {
+		return err
+	}
+
+	symbols := []sobek.Value{registered}
+
+	if native := symbol.Get("asyncIterator"); native != nil && !sobek.IsUndefined(native) {
+		symbols = append(symbols, native)
+	}
+
+	for _, s := range symbols {
+		if sym, ok := s.(*sobek.Symbol); ok {
+			if err := obj.SetSymbol(sym, self); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// notifyIterators passes the message to every message iterator with a matching filter.
+// Messages dropped because the buffer of an iterator is full are counted like those of the inbound queue.
+func (c *client) notifyIterators(msg *receivedMessage) {
+	c.iterators.Range(func(_, v any) bool {
+		it, _ := v.(*messageIterator)
+
+		if !it.matches(msg.topic) {
+			return true
+		}
+
+		if dropped := it.push(msg); dropped != nil {
+			c.log.WithField("topic", dropped.topic).Debug("Message iterator buffer full, message dropped")
+
+			metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, metrics.Samples{
+				c.droppedSample(dropped.topic, time.Now()),
+			})
+		}
+
+		return true
+	})
+}
+
+// closeIterators ends all message iterators, resolving their pending next() calls.
+func (c *client) closeIterators() {
+	c.iterators.Range(func(k, v any) bool {
+		if it, ok := v.(*messageIterator); ok {
+			it.close()
+		}
+
+		c.iterators.Delete(k)
+
+		return true
+	})
+}
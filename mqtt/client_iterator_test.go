@@ -0,0 +1,162 @@
+package mqtt
+
+import (
+	"os"
+	"testing"
+
+	"github.com/grafana/sobek"
+	"github.com/grafana/xk6-mqtt/internal/broker"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/v2/metrics"
+)
+
+func Test_messageIterator_overflow(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		overflow string
+		want     []string
+		dropped  string
+		wantErr  bool
+	}{
+		{overflow: overflowDropOldest, want: []string{"b", "c"}, dropped: "a"},
+		{overflow: overflowDropNewest, want: []string{"a", "b"}, dropped: "c"},
+		{overflow: overflowError, want: []string{"a", "b"}, dropped: "c", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.overflow, func(t *testing.T) {
+			t.Parallel()
+
+			opts := &messagesOptions{Buffer: 2, Overflow: tt.overflow}
+
+			require.NoError(t, opts.validate())
+
+			it := newMessageIterator("", opts)
+
+			require.Nil(t, it.push(&receivedMessage{topic: "a"}))
+			require.Nil(t, it.push(&receivedMessage{topic: "b"}))
+
+			dropped := it.push(&receivedMessage{topic: "c"})
+
+			require.NotNil(t, dropped)
+			require.Equal(t, tt.dropped, dropped.topic)
+
+			if !tt.wantErr {
+				it.close()
+			}
+
+			var got []string
+
+			for {
+				msg, err := it.take(nil)
+				if tt.wantErr && err != nil {
+					require.ErrorIs(t, err, errIteratorOverflow)
+
+					break
+				}
+
+				require.NoError(t, err)
+
+				if msg == nil {
+					break
+				}
+
+				got = append(got, msg.topic)
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_messagesOptions_validate(t *testing.T) {
+	t.Parallel()
+
+	opts := new(messagesOptions)
+
+	require.NoError(t, opts.validate())
+	require.Equal(t, defaultIteratorBuffer, opts.Buffer)
+	require.Equal(t, overflowDropOldest, opts.Overflow)
+
+	require.ErrorIs(t, (&messagesOptions{Overflow: "block"}).validate(), errInvalidOverflow)
+}
+
+func TestClientMessagesOverflow(t *testing.T) {
+	t.Parallel()
+
+	runtime := newTestRuntime(t)
+	mm := newMqttMetrics(runtime.VU)
+	logger := runtime.VU.InitEnv().Logger
+	state, samples := newTestVUStateWithSamples(t)
+
+	runtime.MoveToVUContext(state)
+
+	rt := runtime.VU.Runtime()
+	topic := "test/iterator/overflow"
+
+	client := newTestClient(t, logger, runtime.VU, mm)
+
+	small, err := client.messages(rt.ToValue(topic), &messagesOptions{Buffer: 1, Overflow: overflowError})
+	require.NoError(t, err)
+
+	var errorMethod string
+
+	onEvent(t, client, "error", func(_ sobek.Value, args ...sobek.Value) (sobek.Value, error) {
+		errorMethod = args[0].ToObject(rt).Get("method").String()
+
+		return sobek.Undefined(), nil
+	})
+
+	received := 0
+
+	onEvent(t, client, "message", func(_ sobek.Value, _ ...sobek.Value) (sobek.Value, error) {
+		if received++; received < 2 {
+			return sobek.Undefined(), nil
+		}
+
+		// The buffered message is returned first, then the overflow rejects the promise.
+		_, err := rt.RunString(`small.next().then(() => small.next()).catch((e) => { rejected = e.method }).finally(done)`)
+
+		return sobek.Undefined(), err
+	})
+
+	require.NoError(t, rt.Set("small", small))
+	require.NoError(t, rt.Set("done", func() { require.NoError(t, client.end(nil)) }))
+
+	err = runtime.EventLoop.Start(func() error {
+		_, err := client.connect(rt.ToValue(os.Getenv(broker.EnvBrokerAddress)), nil) //nolint:forbidigo // test reads the embedded broker address from env
+		require.NoError(t, err)
+		require.NoError(t, client.subscribe(rt.ToValue(topic), &subscribeOptions{Qos: 1}))
+		require.NoError(t, client.publish(topic, rt.ToValue("first"), &publishOptions{Qos: 1}))
+		require.NoError(t, client.publish(topic, rt.ToValue("second"), &publishOptions{Qos: 1}))
+
+		return nil
+	})
+
+	require.NoError(t, err)
+
+	runtime.EventLoop.WaitOnRegistered()
+
+	require.Equal(t, "messages", rt.Get("rejected").String())
+	require.Equal(t, "messages", errorMethod)
+
+	collected := collectSamples(samples, nil)
+
+	var dropped, errs []metrics.Sample
+
+	for _, sample := range collected {
+		switch sample.Metric {
+		case mm.mqttMessagesDropped:
+			dropped = append(dropped, sample)
+		case mm.mqttErrors:
+			errs = append(errs, sample)
+		}
+	}
+
+	require.Len(t, dropped, 1)
+	require.Len(t, errs, 1)
+
+	method, _ := errs[0].Tags.Get("method")
+	require.Equal(t, "messages", method)
+}
@@ -1,22 +1,57 @@
 package mqtt
 
-import "github.com/mstoykov/k6-taskqueue-lib/taskqueue"
+import (
+	"github.com/grafana/sobek"
+	"github.com/mstoykov/k6-taskqueue-lib/taskqueue"
+)
 
-func (c *client) loop() {
+// loop passes the queued calls to the event loop of the VU until stop is closed or the VU context is done.
+func (c *client) loop(stop chan struct{}) {
 	ctx := c.vu.Context()
 	tq := taskqueue.New(c.vu.RegisterCallback)
 
 	defer tq.Close()
-	defer c.stopLoop()
 
 	for {
 		select {
 		case call := <-c.callChan:
 			tq.Queue(call)
-		case <-c.stop:
+		case <-stop:
 			return
 		case <-ctx.Done():
+			c.loopMu.Lock()
+			c.closeStop(stop)
+			c.loopMu.Unlock()
+
 			return
 		}
 	}
 }
+
+// newPromise is like promises.New, but the value is built by resolve on the event loop,
+// since JavaScript values must not be created on other goroutines.
+// If build fails, the promise is rejected with the error.
+func (c *client) newPromise() (*sobek.Promise, func(build func(rt *sobek.Runtime) (any, error)), func(reason any)) {
+	rt := c.vu.Runtime()
+	promise, resolveFunc, rejectFunc := rt.NewPromise()
+	callback := c.vu.RegisterCallback()
+
+	resolve := func(build func(rt *sobek.Runtime) (any, error)) {
+		callback(func() error {
+			value, err := build(rt)
+			if err != nil {
+				return rejectFunc(err)
+			}
+
+			return resolveFunc(value)
+		})
+	}
+
+	reject := func(reason any) {
+		callback(func() error {
+			return rejectFunc(reason)
+		})
+	}
+
+	return promise, resolve, reject
+}
@@ -35,29 +35,71 @@ func addToTagSet(ts *metrics.TagSet, tags map[string]string) *metrics.TagSet {
 	return ts
 }
 
-func (c *client) tags() *metrics.TagSet {
-	tags := c.currentTags()
+// connectionTags holds the details of the current connection used as metric tags.
+// It is replaced as a whole, so tags can be computed on any goroutine without locking the client.
+type connectionTags struct {
+	version  uint
+	clientID string
+	url      string
+	tags     map[string]string
+}
 
-	tags = tags.With("proto", "MQTT/3.1.1")
+// snapshotTags records the tags of the current connection. It must be called with the lock held.
+func (c *client) snapshotTags() {
+	conn := &connectionTags{tags: c.connOpts.Tags}
 
 	if c.pahoClient != nil {
 		opts := c.pahoClient.OptionsReader()
 
-		if cid := opts.ClientID(); cid != "" {
-			tags = tags.With("client_id", cid)
+		conn.version = opts.ProtocolVersion()
+		conn.clientID = opts.ClientID()
+		conn.url = c.url
+	}
+
+	c.connTags.Store(conn)
+}
+
+func (c *client) tags() *metrics.TagSet {
+	tags := c.currentTags()
+
+	version := c.clientOpts.ProtocolVersion
+
+	var connTags map[string]string
+
+	if conn := c.connTags.Load(); conn != nil {
+		if conn.version != 0 {
+			version = conn.version
+		}
+
+		if conn.clientID != "" {
+			tags = tags.With("client_id", conn.clientID)
 		}
 
-		if c.url != "" {
-			tags = tags.With("url", c.url)
+		if conn.url != "" {
+			tags = tags.With("url", conn.url)
 		}
+
+		connTags = conn.tags
 	}
 
+	tags = tags.With("proto", protocolName(version))
 	tags = addToTagSet(tags, c.clientOpts.Tags)
-	tags = addToTagSet(tags, c.connOpts.Tags)
+	tags = addToTagSet(tags, connTags)
 
 	return tags
 }
 
+func protocolName(version uint) string {
+	switch version {
+	case protocolVersion31:
+		return "MQTT/3.1"
+	case protocolVersion5:
+		return "MQTT/5.0"
+	default:
+		return "MQTT/3.1.1"
+	}
+}
+
 func (c *client) tagsForMethod(method string, dict map[string]string, nv ...string) *metrics.TagSet {
 	if len(nv)%2 != 0 {
 		panic(fmt.Errorf("%w: expected even number of tags", errWrongNumberOfArgs))
@@ -100,3 +142,18 @@ func (c *client) addCallMetrics(method string, tags map[string]string, nv ...str
 		},
 	})
 }
+
+func (c *client) addDurationMetrics(
+	metric *metrics.Metric, method string, duration time.Duration, tags map[string]string, nv ...string,
+) {
+	metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, metrics.Samples{
+		metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: metric,
+				Tags:   c.tagsForMethod(method, tags, nv...),
+			},
+			Time:  time.Now(),
+			Value: metrics.D(duration),
+		},
+	})
+}
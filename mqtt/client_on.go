@@ -1,7 +1,13 @@
 package mqtt
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
 	paho "github.com/eclipse/paho.mqtt.golang"
@@ -16,67 +22,195 @@ var events = map[string]struct{}{ //nolint:gochecknoglobals
 	"end":       {},
 	"error":     {},
 	"message":   {},
+	"offline":   {},
+	"close":     {},
 }
 
-func (c *client) on(event string, handler sobek.Callable) {
+// listener is an event listener registered with on() or once().
+type listener struct {
+	handler sobek.Value
+	fn      sobek.Callable
+	once    bool
+}
+
+func (c *client) on(event string, handler sobek.Value) error {
+	return c.addListener(event, handler, false)
+}
+
+func (c *client) once(event string, handler sobek.Value) error {
+	return c.addListener(event, handler, true)
+}
+
+func (c *client) addListener(event string, handler sobek.Value, once bool) error {
 	if _, ok := events[event]; !ok {
 		c.log.WithField("event", event).Warn("Unknown event type")
 
-		return
+		return nil
 	}
 
-	if _, ok := c.handlers.Load(event); ok {
-		c.log.WithField("event", event).Warn("Event handler already registered, overriding")
+	fn, ok := sobek.AssertFunction(handler)
+	if !ok {
+		return fmt.Errorf("%w: Function expected", errInvalidType)
 	}
 
+	c.listenersMu.Lock()
+	defer c.listenersMu.Unlock()
+
+	// Listener lists are never modified in place, so fire() can use them without holding the lock.
+	c.listeners[event] = append(slices.Clip(c.listeners[event]), &listener{handler: handler, fn: fn, once: once})
+
 	c.log.WithField("event", event).Debug("Event handler registered")
 
-	c.handlers.Store(event, handler)
+	return nil
 }
 
-func (c *client) fire(event string, args ...sobek.Value) bool {
-	f, ok := c.handlers.Load(event)
-	if !ok {
-		return false
+func (c *client) off(event string, handler sobek.Value) {
+	c.listenersMu.Lock()
+	defer c.listenersMu.Unlock()
+
+	list := c.listeners[event]
+
+	for i, l := range list {
+		if l.handler.SameAs(handler) {
+			c.listeners[event] = slices.Delete(slices.Clone(list), i, i+1)
+
+			c.log.WithField("event", event).Debug("Event handler removed")
+
+			return
+		}
 	}
+}
 
-	fn, ok := f.(sobek.Callable)
-	if !ok {
+func (c *client) removeAllListeners(event sobek.Value) {
+	c.listenersMu.Lock()
+	defer c.listenersMu.Unlock()
+
+	if event == nil || sobek.IsUndefined(event) || sobek.IsNull(event) {
+		clear(c.listeners)
+
+		return
+	}
+
+	delete(c.listeners, event.String())
+}
+
+// fire queues the call of the listeners of the event. The arguments are converted to JavaScript on the event loop.
+func (c *client) fire(event string, args ...any) bool {
+	call := c.listenerCall(event, args...)
+	if call == nil {
 		return false
 	}
 
+	return c.enqueue(event, call, nil)
+}
+
+// listenerCall returns a function calling the listeners of the event, removing the listeners registered with once().
+// It returns nil if the event has no listeners.
+func (c *client) listenerCall(event string, args ...any) func() error {
+	c.listenersMu.Lock()
+
+	list := c.listeners[event]
+
+	if slices.ContainsFunc(list, (*listener).isOnce) {
+		c.listeners[event] = slices.DeleteFunc(slices.Clone(list), (*listener).isOnce)
+	}
+
+	c.listenersMu.Unlock()
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	return func() error {
+		values, err := toValues(c.vu.Runtime(), args)
+		if err != nil {
+			return err
+		}
+
+		for _, l := range list {
+			if _, err := l.fn(sobek.Undefined(), values...); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+func (l *listener) isOnce() bool {
+	return l.once
+}
+
+// invoke queues a call of the handler on the event loop. The arguments are converted to JavaScript on the event loop.
+func (c *client) invoke(event string, fn sobek.Callable, args ...any) bool {
+	return c.enqueue(event, c.handlerCall(fn, args...), nil)
+}
+
+func (c *client) handlerCall(fn sobek.Callable, args ...any) func() error {
+	return func() error {
+		values, err := toValues(c.vu.Runtime(), args)
+		if err != nil {
+			return err
+		}
+
+		_, err = fn(sobek.Undefined(), values...)
+
+		return err
+	}
+}
+
+// enqueue queues fn on the event loop. If done is not nil, it is closed once fn returned.
+func (c *client) enqueue(event string, fn func() error, done chan<- struct{}) bool {
 	c.log.WithField("event", event).Debug("Queuing event handler")
 
 	call := func() error {
-		c.log.WithField("event", event).Debug("Firing event handler")
+		if done != nil {
+			defer close(done)
+		}
 
-		_, err := fn(sobek.Undefined(), args...)
+		c.log.WithField("event", event).Debug("Firing event handler")
 
-		return err
+		return fn()
 	}
 
 	select {
 	case c.callChan <- call:
 		return true
-	case <-c.stop:
+	case <-c.stopped():
 		return false
 	}
 }
 
 func (c *client) messageHandler(_ paho.Client, msg paho.Message) {
+	c.handleMessage(msg, nil, "")
+}
+
+// subscriptionHandler returns a message handler passing the messages of a subscription to its own callback,
+// or to the message event handler if handler is nil, with the payload decoded as format.
+func (c *client) subscriptionHandler(handler sobek.Callable, format string) paho.MessageHandler {
+	return func(_ paho.Client, msg paho.Message) {
+		c.handleMessage(msg, handler, format)
+	}
+}
+
+// handleMessage records the metrics of a received message and passes it to the handler,
+// or to the message event handler if handler is nil. The payload is decoded as format,
+// falling back to the payload_format client option.
+func (c *client) handleMessage(msg paho.Message, handler sobek.Callable, format string) {
 	c.log.WithFields(logrus.Fields{
 		"topic":     msg.Topic(),
 		"messageID": msg.MessageID(),
 	}).Debug("Received MQTT message")
 
-	rt := c.vu.Runtime()
-
-	payload := rt.NewArrayBuffer(msg.Payload())
-
 	now := time.Now()
+	stamp, data, stamped := c.unstampIncoming(msg)
 	bytes := float64(len(msg.Payload()))
 	tags := c.tags().With("topic", msg.Topic())
 
+	if group := c.shareGroup(msg.Topic()); group != "" {
+		tags = tags.With("share_group", group)
+	}
+
 	samples := metrics.Samples{
 		metrics.Sample{
 			TimeSeries: metrics.TimeSeries{
@@ -104,37 +238,226 @@ func (c *client) messageHandler(_ paho.Client, msg paho.Message) {
 		},
 	}
 
+	if stamped && !stamp.sentAt.IsZero() {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.mqttMessageLatency,
+				Tags:   tags,
+			},
+			Time:  now,
+			Value: metrics.D(max(now.Sub(stamp.sentAt), 0)),
+		})
+	}
+
+	if stamped && stamp.seq != 0 {
+		counts := c.sequences.observe(stamp.source, msg.Topic(), stamp.seq, tags)
+
+		samples = append(samples, c.sequenceSamples(counts, tags, now)...)
+	}
+
+	// The broker sets the duplicate flag on messages sent again after the session was resumed.
+	if msg.Duplicate() {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.mqttMessagesRedelivered,
+				Tags:   tags.With("direction", "received"),
+			},
+			Time:  now,
+			Value: float64(1),
+		})
+	}
+
 	metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, samples)
 
-	c.fire("message", rt.ToValue(msg.Topic()), rt.ToValue(payload))
+	if msg.Topic() == c.replyTopic {
+		c.handleReply(msg, data)
+
+		return
+	}
+
+	packet := messagePacket(msg, now)
+
+	received := &receivedMessage{topic: msg.Topic(), payload: data, packet: packet}
+
+	c.notifyWaiters(received)
+	c.notifyIterators(received)
+
+	payload, err := decodePayload(data, c.payloadFormat(format))
+	if err != nil {
+		_ = c.handleError(err, "message", nil, "topic", msg.Topic())
+
+		return
+	}
+
+	args := []any{msg.Topic(), payload, packet}
+
+	var call func() error
+
+	if handler != nil {
+		call = c.handlerCall(handler, args...)
+	} else if call = c.listenerCall("message", args...); call == nil {
+		return
+	}
+
+	c.dispatchMessage(msg.Topic(), call)
+}
+
+// messagePacket returns the packet details passed to the message event handler as third argument.
+func messagePacket(msg paho.Message, received time.Time) map[string]any {
+	packet := map[string]any{
+		"qos":         msg.Qos(),
+		"retain":      msg.Retained(),
+		"dup":         msg.Duplicate(),
+		"message_id":  msg.MessageID(),
+		"received_at": received.UnixMilli(),
+	}
+
+	if mp, ok := msg.(messageWithProperties); ok && mp.Properties() != nil {
+		props := mp.Properties()
+		user := make(map[string]any, len(props.User))
+
+		for _, prop := range props.User {
+			user[prop.Key] = prop.Value
+		}
+
+		properties := map[string]any{"user": user}
+
+		if props.ResponseTopic != "" {
+			properties["response_topic"] = props.ResponseTopic
+		}
+
+		if props.CorrelationData != nil {
+			properties["correlation_data"] = string(props.CorrelationData)
+		}
+
+		packet["properties"] = properties
+	}
+
+	return packet
 }
 
 func (c *client) connectHandler(_ paho.Client) {
 	c.log.Debug("Connected to MQTT broker")
 
+	if !c.connected.Swap(true) {
+		// The first connection is reported by connectExecute, together with its CONNACK.
+		return
+	}
+
+	// Reconnected automatically, the reply topic subscription may be gone with the session.
+	c.replySubscribed.Store(false)
+
+	if c.connOpts.ResumeSubs {
+		c.resumeSubscriptions()
+	}
+
+	// The CONNACK of automatic reconnects is only available with MQTT 5.
+	if ack := c.lastConnack(); ack != nil {
+		c.fire("connect", ack.export())
+
+		return
+	}
+
 	c.fire("connect")
 }
 
+// lastConnack returns the CONNACK of the latest connection, if the client keeps it.
+func (c *client) lastConnack() *connack {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if cr, ok := c.pahoClient.(connackReader); ok {
+		return cr.lastConnack()
+	}
+
+	return nil
+}
+
 func (c *client) reconnectHandler(_ paho.Client, _ *paho.ClientOptions) {
 	c.log.Debug("Reconnecting to MQTT broker")
 
+	metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, metrics.Samples{
+		metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.mqttReconnects,
+				Tags:   c.tags(),
+			},
+			Time:  time.Now(),
+			Value: float64(1),
+		},
+	})
+
 	c.fire("reconnect")
 }
 
-func (c *client) handleError(err error, method string, tags map[string]string, nv ...string) error {
-	c.log.WithField("error", err).WithField("method", method).Error("MQTT error occurred")
+// connectionLostHandler is called when the connection to the broker drops unexpectedly.
+func (c *client) connectionLostHandler(_ paho.Client, err error) {
+	reason := connectionLostReason(err)
 
-	c.addErrorMetrics(method, tags, nv...)
+	c.log.WithField("error", err).WithField("reason", reason).Warn("Connection to MQTT broker lost")
 
-	wrapped := newMQTTError(err, method)
+	metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, metrics.Samples{
+		metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.mqttConnectionLost,
+				Tags:   c.tags().With("reason", reason),
+			},
+			Time:  time.Now(),
+			Value: float64(1),
+		},
+	})
+
+	event := map[string]any{"reason": reason, "message": err.Error()}
+
+	c.fire("offline", event)
+
+	if !c.connOpts.autoReconnect() {
+		c.fire("close", event)
+	}
+}
+
+// connectionLostReason classifies the error that caused the connection to drop.
+func connectionLostReason(err error) string {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, errServerDisconnect):
+		return "server_disconnect"
+	case strings.Contains(strings.ToLower(err.Error()), "pingresp"):
+		return "keepalive_timeout"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, net.ErrClosed),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return "connection_closed"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network_error"
+	default:
+		return "unknown"
+	}
+}
 
-	if c.fire("error", c.vu.Runtime().ToValue(wrapped)) {
+func (c *client) handleError(err error, method string, tags map[string]string, nv ...string) error {
+	wrapped, handled := c.reportError(err, method, tags, nv...)
+	if handled {
 		return nil
 	}
 
 	return wrapped
 }
 
+// reportError logs the error, records its metrics and passes it to the error event.
+// It returns the wrapped error, and whether an error listener was called.
+func (c *client) reportError(err error, method string, tags map[string]string, nv ...string) (*MQTTError, bool) {
+	c.log.WithField("error", err).WithField("method", method).Error("MQTT error occurred")
+
+	c.addErrorMetrics(method, tags, nv...)
+
+	wrapped := newMQTTError(err, method)
+
+	return wrapped, c.fire("error", wrapped)
+}
+
 // MQTTError represents an error that occurred during an MQTT operation.
 type MQTTError struct { //nolint:revive
 	Name    string
@@ -0,0 +1,180 @@
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"sync"
+
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/v2/js/promises"
+)
+
+var errInvalidPoolSize = errors.New("invalid client pool size")
+
+type poolOptions struct {
+	Size int
+}
+
+// clientPool is a set of clients sharing the event loop and the metrics of a VU.
+type clientPool struct {
+	clients []*client
+}
+
+func (m *module) clientPool(call sobek.ConstructorCall) *sobek.Object {
+	rt := m.vu.Runtime()
+	toValue := rt.ToValue
+
+	poolOpts := new(poolOptions)
+	opts := new(clientOptions)
+
+	if len(call.Arguments) > 0 {
+		m.must(rt.ExportTo(call.Arguments[0], &poolOpts))
+		m.must(rt.ExportTo(call.Arguments[0], &opts))
+	}
+
+	if poolOpts.Size <= 0 {
+		m.must(fmt.Errorf("%w: %d", errInvalidPoolSize, poolOpts.Size))
+	}
+
+	m.must(opts.validate())
+
+	pool := &clientPool{clients: make([]*client, 0, poolOpts.Size)}
+	objects := make([]any, 0, poolOpts.Size)
+
+	for index := range poolOpts.Size {
+		obj := rt.NewObject()
+
+		pool.clients = append(pool.clients, m.newClientObject(obj, opts.forPoolIndex(index, rt)))
+		objects = append(objects, obj)
+	}
+
+	this := call.This
+
+	m.must(this.Set("size", poolOpts.Size))
+	m.must(this.Set("clients", rt.NewArray(objects...)))
+	m.must(this.Set("connect", toValue(pool.connect)))
+	m.must(this.Set("connectAsync", toValue(pool.connectAsync)))
+	m.must(this.Set("end", toValue(pool.end)))
+	m.must(this.Set("endAsync", toValue(pool.endAsync)))
+
+	return nil
+}
+
+// forPoolIndex returns the options of the pool client with the given index,
+// expanding the {index} placeholder of the client ID, username and password templates.
+// The other placeholders of the client ID are expanded when connecting, those of the username and password are sent as they are.
+// Nested options are copied, so the clients of the pool do not share them.
+func (co *clientOptions) forPoolIndex(index int, runtime *sobek.Runtime) *clientOptions {
+	opts := *co
+	opts.Will = clone(co.Will)
+	opts.Tls = clone(co.Tls)
+	opts.InboundQueue = clone(co.InboundQueue)
+	opts.Rate = clone(co.Rate)
+	opts.Store = clone(co.Store)
+	opts.Tags = maps.Clone(co.Tags)
+
+	vars := map[string]string{"index": strconv.Itoa(index)}
+
+	expand := func(value sobek.Value) sobek.Value {
+		if !sobek.IsString(value) {
+			return value
+		}
+
+		return runtime.ToValue(replacePlaceholders(value.String(), vars))
+	}
+
+	opts.ClientId = expand(co.ClientId)
+	opts.Username = expand(co.Username)
+	opts.Password = expand(co.Password)
+
+	return &opts
+}
+
+// clone returns a copy of the value p points to, or nil if p is nil.
+func clone[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+
+	v := *p
+
+	return &v
+}
+
+// connect connects all clients one after the other, returning the first error.
+func (p *clientPool) connect(urlOrOpts sobek.Value, optsOrEmpty sobek.Value) error {
+	for _, c := range p.clients {
+		if _, err := c.connect(urlOrOpts, optsOrEmpty); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// connectAsync connects all clients concurrently. The promise is rejected with the first error.
+func (p *clientPool) connectAsync(urlOrOpts sobek.Value, optsOrEmpty sobek.Value) (*sobek.Promise, error) {
+	for _, c := range p.clients {
+		if err := c.connectPrepare(urlOrOpts, optsOrEmpty); err != nil {
+			return nil, err
+		}
+	}
+
+	promise, resolve, reject := promises.New(p.clients[0].vu)
+
+	go func() {
+		var (
+			wg       sync.WaitGroup
+			once     sync.Once
+			firstErr error
+		)
+
+		for _, c := range p.clients {
+			wg.Go(func() {
+				if _, err := c.connectExecute(); err != nil {
+					once.Do(func() { firstErr = err })
+				}
+			})
+		}
+
+		wg.Wait()
+
+		if firstErr != nil {
+			reject(firstErr)
+
+			return
+		}
+
+		resolve(nil)
+	}()
+
+	return promise, nil
+}
+
+func (p *clientPool) end(opts *endOptions) error {
+	for _, c := range p.clients {
+		if err := c.end(opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *clientPool) endAsync(opts *endOptions) (*sobek.Promise, error) {
+	promise, resolve, reject := promises.New(p.clients[0].vu)
+
+	go func() {
+		if err := p.end(opts); err != nil {
+			reject(err)
+
+			return
+		}
+
+		resolve(nil)
+	}()
+
+	return promise, nil
+}
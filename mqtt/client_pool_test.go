@@ -0,0 +1,45 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/grafana/sobek"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_clientOptions_forPoolIndex(t *testing.T) {
+	t.Parallel()
+
+	rt := sobek.New()
+
+	opts := &clientOptions{
+		ClientId:     rt.ToValue("device-{index}-{vu}"),
+		Username:     rt.ToValue("user-{index}-{vu}"),
+		Tls:          &tlsOptions{ServerName: "broker"},
+		InboundQueue: &inboundQueueOptions{Size: 10},
+		Rate:         &rateOptions{MessagesPerSecond: 1},
+		Store:        &storeOptions{Type: storeTypeFile, Dir: "sessions"},
+		Tags:         map[string]string{"fleet": "a"},
+	}
+
+	first := opts.forPoolIndex(0, rt)
+	second := opts.forPoolIndex(1, rt)
+
+	// The other client ID placeholders are expanded when connecting, those of the username are not.
+	require.Equal(t, "device-0-{vu}", first.ClientId.String())
+	require.Equal(t, "device-1-{vu}", second.ClientId.String())
+	require.Equal(t, "user-0-{vu}", first.Username.String())
+
+	first.Tls.ServerName = "other"
+	first.InboundQueue.Size = 20
+	first.Rate.MessagesPerSecond = 2
+	first.Store.Dir = "other"
+	first.Tags["fleet"] = "b"
+
+	require.Equal(t, "broker", second.Tls.ServerName)
+	require.Equal(t, 10, second.InboundQueue.Size)
+	require.InDelta(t, 1, second.Rate.MessagesPerSecond, 0)
+	require.Equal(t, "sessions", second.Store.Dir)
+	require.Equal(t, "a", second.Tags["fleet"])
+	require.Equal(t, "a", opts.Tags["fleet"])
+}
@@ -2,10 +2,12 @@ package mqtt
 
 import (
 	"errors"
-	"fmt"
-	"reflect"
+	"sort"
+	"strconv"
 	"time"
 
+	paho5 "github.com/eclipse/paho.golang/paho"
+	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/grafana/sobek"
 	"go.k6.io/k6/v2/js/promises"
 	"go.k6.io/k6/v2/metrics"
@@ -13,10 +15,40 @@ import (
 
 var errInvalidType = errors.New("invalid type")
 
+type publishProperties struct {
+	User            map[string]string
+	ResponseTopic   string
+	CorrelationData string
+}
+
+func (pp *publishProperties) toPaho() *paho5.PublishProperties {
+	props := &paho5.PublishProperties{ResponseTopic: pp.ResponseTopic}
+
+	if len(pp.CorrelationData) != 0 {
+		props.CorrelationData = []byte(pp.CorrelationData)
+	}
+
+	keys := make([]string, 0, len(pp.User))
+
+	for k := range pp.User {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		props.User.Add(k, pp.User[k])
+	}
+
+	return props
+}
+
 type publishOptions struct {
-	Qos    byte
-	Retain bool
-	Tags   map[string]string
+	Qos        byte
+	Retain     bool
+	Encoding   string
+	Properties *publishProperties
+	Tags       map[string]string
 }
 
 func (c *client) publish(topic string, message sobek.Value, opts *publishOptions) error {
@@ -57,29 +89,55 @@ func (c *client) publishAsync(topic string, message sobek.Value, opts *publishOp
 func (c *client) publishPrepare(
 	topic string, message sobek.Value, opts *publishOptions,
 ) (string, []byte, *publishOptions, error) {
+	if opts == nil {
+		opts = &publishOptions{}
+	}
+
 	if !c.isConnected() {
-		return "", nil, nil, errNotConnected
+		return topic, nil, opts, errNotConnected
 	}
 
-	data, err := stringOrArrayBuffer(message, c.vu.Runtime())
+	data, err := encodePayload(message, opts.Encoding, c.vu.Runtime())
 	if err != nil {
-		return "", nil, nil, err
+		return topic, nil, opts, err
 	}
 
-	if opts == nil {
-		opts = &publishOptions{}
+	if opts.Properties != nil && !c.supportsProperties() {
+		return topic, nil, opts, errPropertiesNotSupported
 	}
 
 	return topic, data, opts, nil
 }
 
+// supportsProperties reports whether the client is connected with a protocol supporting MQTT 5 properties.
+func (c *client) supportsProperties() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	_, ok := c.pahoClient.(propertiesPublisher)
+
+	return ok
+}
+
 func (c *client) publishExecute(topic string, message []byte, opts *publishOptions) error {
+	// Throttled before locking, so waiting for the rate limiter does not block connect or end.
+	if err := c.throttle(topic, opts.Tags); err != nil {
+		return err
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	if c.pahoClient == nil {
+		return errNotConnected
+	}
+
 	c.log.Debug("Publishing message to MQTT broker")
 
-	token := c.pahoClient.Publish(topic, opts.Qos, opts.Retain, message)
+	start := time.Now()
+
+	token, message := c.publishSend(topic, message, opts, start)
+
 	if token.Wait() && token.Error() != nil {
 		if err := c.handleError(token.Error(), "publish", opts.Tags, "topic", topic); err != nil {
 			return err
@@ -88,8 +146,31 @@ func (c *client) publishExecute(topic string, message []byte, opts *publishOptio
 		return nil
 	}
 
+	metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, c.publishSamples(topic, len(message), opts, start))
+
+	return nil
+}
+
+// publishSend stamps and sends the message, returning the token of the publish and the payload sent.
+func (c *client) publishSend(topic string, message []byte, opts *publishOptions, start time.Time) (paho.Token, []byte) {
+	var props *paho5.PublishProperties
+
+	if opts.Properties != nil {
+		props = opts.Properties.toPaho()
+	}
+
+	message, props = c.stampOutgoing(topic, message, props, start)
+
+	if pp, ok := c.pahoClient.(propertiesPublisher); ok && props != nil {
+		return pp.PublishWithProperties(topic, opts.Qos, opts.Retain, message, props), message
+	}
+
+	return c.pahoClient.Publish(topic, opts.Qos, opts.Retain, message), message
+}
+
+// publishSamples returns the metrics of a message published successfully.
+func (c *client) publishSamples(topic string, size int, opts *publishOptions, start time.Time) metrics.Samples {
 	now := time.Now()
-	bytes := float64(len(message))
 	tags := c.tags().With("topic", topic)
 
 	samples := metrics.Samples{
@@ -115,36 +196,21 @@ func (c *client) publishExecute(topic string, message []byte, opts *publishOptio
 				Tags:   c.currentTags(),
 			},
 			Time:  now,
-			Value: bytes,
+			Value: float64(size),
 		},
 	}
 
-	metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, samples)
-
-	return nil
-}
-
-func stringOrArrayBuffer(input sobek.Value, runtime *sobek.Runtime) ([]byte, error) {
-	var data []byte
-
-	switch input.ExportType() {
-	case reflect.TypeFor[string]():
-		var str string
-
-		if err := runtime.ExportTo(input, &str); err != nil {
-			return nil, err
-		}
-
-		data = []byte(str)
-
-	case reflect.TypeFor[[]byte]():
-		if err := runtime.ExportTo(input, &data); err != nil {
-			return nil, err
-		}
-
-	default:
-		return nil, fmt.Errorf("%w: String or ArrayBuffer expected", errInvalidType)
+	// Only QoS 1 and 2 messages are acknowledged by the broker (PUBACK or PUBCOMP).
+	if opts.Qos > 0 {
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.mqttPublishDuration,
+				Tags:   c.tagsForMethod("publish", opts.Tags, "topic", topic, "qos", strconv.Itoa(int(opts.Qos))),
+			},
+			Time:  now,
+			Value: metrics.D(now.Sub(start)),
+		})
 	}
 
-	return data, nil
+	return samples
 }
@@ -0,0 +1,200 @@
+package mqtt
+
+import (
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/grafana/sobek"
+	"go.k6.io/k6/v2/js/promises"
+	"go.k6.io/k6/v2/metrics"
+)
+
+// batchMessage is a message published with publishMany().
+type batchMessage struct {
+	Topic      string
+	Payload    sobek.Value
+	Qos        byte
+	Retain     bool
+	Encoding   string
+	Properties *publishProperties
+}
+
+type publishManyOptions struct {
+	Tags map[string]string
+}
+
+// preparedMessage is a message of a batch ready to be sent.
+type preparedMessage struct {
+	topic string
+	data  []byte
+	opts  *publishOptions
+	start time.Time
+	token paho.Token
+	err   error
+}
+
+func (c *client) publishMany(messages []batchMessage, opts *publishManyOptions) (sobek.Value, error) {
+	prepared, opts, err := c.publishManyPrepare(messages, opts)
+	if err != nil {
+		if err := c.handleError(err, "publishMany", opts.Tags); err != nil {
+			return nil, err
+		}
+
+		return sobek.Undefined(), nil
+	}
+
+	return c.vu.Runtime().ToValue(c.publishManyExecute(prepared, opts)), nil
+}
+
+func (c *client) publishManyAsync(messages []batchMessage, opts *publishManyOptions) (*sobek.Promise, error) {
+	prepared, opts, err := c.publishManyPrepare(messages, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	promise, resolve, _ := promises.New(c.vu)
+
+	go func() {
+		resolve(c.publishManyExecute(prepared, opts))
+	}()
+
+	return promise, nil
+}
+
+func (c *client) publishManyPrepare(
+	messages []batchMessage, opts *publishManyOptions,
+) ([]*preparedMessage, *publishManyOptions, error) {
+	if opts == nil {
+		opts = new(publishManyOptions)
+	}
+
+	prepared := make([]*preparedMessage, 0, len(messages))
+
+	for _, msg := range messages {
+		payload := msg.Payload
+		if payload == nil {
+			payload = sobek.Undefined()
+		}
+
+		topic, data, o, err := c.publishPrepare(msg.Topic, payload, &publishOptions{
+			Qos:        msg.Qos,
+			Retain:     msg.Retain,
+			Encoding:   msg.Encoding,
+			Properties: msg.Properties,
+			Tags:       opts.Tags,
+		})
+		if err != nil {
+			return nil, opts, err
+		}
+
+		prepared = append(prepared, &preparedMessage{topic: topic, data: data, opts: o})
+	}
+
+	return prepared, opts, nil
+}
+
+// publishManyExecute sends all messages before waiting for their acknowledgements,
+// and returns the result of every message along with the time until all were acknowledged.
+func (c *client) publishManyExecute(prepared []*preparedMessage, opts *publishManyOptions) map[string]any {
+	c.log.WithField("count", len(prepared)).Debug("Publishing messages to MQTT broker")
+
+	// Held while waiting for the acknowledgements too, so end waits for the batch like for publish.
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	start := c.publishManySend(prepared, opts)
+
+	var samples metrics.Samples
+
+	results := make([]map[string]any, 0, len(prepared))
+
+	for _, msg := range prepared {
+		result := map[string]any{"topic": msg.topic, "error": nil}
+
+		if msg.err == nil && msg.token.Wait() {
+			msg.err = msg.token.Error()
+		}
+
+		if msg.err != nil {
+			// Like publish, the error is passed to the error event, but the batch goes on.
+			wrapped, _ := c.reportError(msg.err, "publish", opts.Tags, "topic", msg.topic)
+
+			result["error"] = wrapped.Error()
+		} else {
+			samples = append(samples, c.publishSamples(msg.topic, len(msg.data), msg.opts, msg.start)...)
+		}
+
+		results = append(results, result)
+	}
+
+	var duration time.Duration
+
+	if !start.IsZero() {
+		duration = time.Since(start)
+	}
+
+	samples = append(samples,
+		metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.mqttCalls,
+				Tags:   c.tagsForMethod("publishMany", opts.Tags),
+			},
+			Time:  time.Now(),
+			Value: float64(1),
+		},
+		metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.mqttPublishBatchDuration,
+				Tags:   c.tagsForMethod("publishMany", opts.Tags),
+			},
+			Time:  time.Now(),
+			Value: metrics.D(duration),
+		},
+	)
+
+	metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, samples)
+
+	return map[string]any{
+		"results":  results,
+		"duration": float64(duration) / float64(time.Millisecond),
+	}
+}
+
+// publishManySend sends the messages of the batch, and returns the time the first one was sent.
+// All messages are sent over the connection of the first one, so the messages left when connect
+// or end replaces it while the batch is throttled fail with errNotConnected.
+// It must be called with the read lock held.
+func (c *client) publishManySend(prepared []*preparedMessage, opts *publishManyOptions) time.Time {
+	var start time.Time
+
+	pahoClient := c.pahoClient
+
+	for _, msg := range prepared {
+		if c.limiter != nil {
+			// Unlocked while throttled, so waiting for the rate limiter does not block connect or end.
+			c.mu.RUnlock()
+			msg.err = c.throttle(msg.topic, opts.Tags)
+			c.mu.RLock()
+
+			if msg.err != nil {
+				continue
+			}
+		}
+
+		if c.pahoClient == nil || c.pahoClient != pahoClient {
+			msg.err = errNotConnected
+
+			continue
+		}
+
+		msg.start = time.Now()
+
+		if start.IsZero() {
+			start = msg.start
+		}
+
+		msg.token, msg.data = c.publishSend(msg.topic, msg.data, msg.opts, msg.start)
+	}
+
+	return start
+}
@@ -3,10 +3,13 @@ package mqtt
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/grafana/sobek"
 	"github.com/grafana/xk6-mqtt/internal/broker"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/v2/metrics"
 )
 
 func TestClientPublish(t *testing.T) {
@@ -22,14 +25,15 @@ func TestClientPublish(t *testing.T) {
 
 	toValue := runtime.VU.Runtime().ToValue
 
-	client.on("connect", func(_ sobek.Value, _ ...sobek.Value) (sobek.Value, error) {
+	onEvent(t, client, "connect", func(_ sobek.Value, _ ...sobek.Value) (sobek.Value, error) {
 		require.NoError(t, client.end(nil))
 
 		return sobek.Undefined(), nil
 	})
 
 	err := runtime.EventLoop.Start(func() error {
-		require.NoError(t, client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), nil)) //nolint:forbidigo // test reads the embedded broker address from env
+		_, err := client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), nil) //nolint:forbidigo // test reads the embedded broker address from env
+		require.NoError(t, err)
 
 		return nil
 	})
@@ -38,3 +42,165 @@ func TestClientPublish(t *testing.T) {
 
 	runtime.EventLoop.WaitOnRegistered()
 }
+
+func TestClientPublishDuration(t *testing.T) {
+	t.Parallel()
+
+	runtime := newTestRuntime(t)
+	mm := newMqttMetrics(runtime.VU)
+	logger := runtime.VU.InitEnv().Logger
+	state, samples := newTestVUStateWithSamples(t)
+
+	runtime.MoveToVUContext(state)
+
+	client := newTestClient(t, logger, runtime.VU, mm)
+
+	toValue := runtime.VU.Runtime().ToValue
+
+	err := runtime.EventLoop.Start(func() error {
+		_, err := client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), nil) //nolint:forbidigo // test reads the embedded broker address from env
+		require.NoError(t, err)
+		require.NoError(t, client.publish("test/duration", toValue("qos0"), nil))
+		require.NoError(t, client.publish("test/duration", toValue("qos1"), &publishOptions{Qos: 1}))
+		require.NoError(t, client.end(nil))
+
+		return nil
+	})
+
+	require.NoError(t, err)
+
+	runtime.EventLoop.WaitOnRegistered()
+
+	durations := collectSamples(samples, mm.mqttPublishDuration)
+
+	require.Len(t, durations, 1)
+
+	qos, ok := durations[0].Tags.Get("qos")
+
+	require.True(t, ok)
+	require.Equal(t, "1", qos)
+}
+
+func TestClientPublishManyDuration(t *testing.T) {
+	t.Parallel()
+
+	runtime := newTestRuntime(t)
+	mm := newMqttMetrics(runtime.VU)
+	logger := runtime.VU.InitEnv().Logger
+	state, samples := newTestVUStateWithSamples(t)
+
+	runtime.MoveToVUContext(state)
+
+	client := newTestClient(t, logger, runtime.VU, mm)
+
+	toValue := runtime.VU.Runtime().ToValue
+
+	err := runtime.EventLoop.Start(func() error {
+		_, err := client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), nil) //nolint:forbidigo // test reads the embedded broker address from env
+		require.NoError(t, err)
+
+		result, err := client.publishMany([]batchMessage{
+			{Topic: "test/batch/duration", Payload: toValue("qos0")},
+			{Topic: "test/batch/duration", Payload: toValue("qos1"), Qos: 1},
+		}, nil)
+		require.NoError(t, err)
+		require.Len(t, result.Export().(map[string]any)["results"], 2) //nolint:forcetypeassert
+
+		require.NoError(t, client.end(nil))
+
+		return nil
+	})
+
+	require.NoError(t, err)
+
+	runtime.EventLoop.WaitOnRegistered()
+
+	counts := make(map[*metrics.Metric]int)
+
+	for _, sample := range collectSamples(samples, nil) {
+		counts[sample.Metric]++
+	}
+
+	require.Equal(t, 1, counts[mm.mqttPublishBatchDuration])
+	require.Equal(t, 1, counts[mm.mqttPublishDuration])
+	require.Equal(t, 2, counts[mm.mqttMessagesSent])
+}
+
+func TestClientPublishManyThrottled(t *testing.T) {
+	t.Parallel()
+
+	runtime := newTestRuntime(t)
+	mm := newMqttMetrics(runtime.VU)
+	logger := runtime.VU.InitEnv().Logger
+	state, _ := newTestVUStateWithSamples(t)
+
+	runtime.MoveToVUContext(state)
+
+	client := newTestClient(t, logger, runtime.VU, mm)
+	client.clientOpts.Rate = &rateOptions{MessagesPerSecond: 5}
+
+	require.NoError(t, client.clientOpts.Rate.validate())
+
+	client.limiter = client.clientOpts.Rate.limiter()
+
+	rt := runtime.VU.Runtime()
+
+	var errorMethods []string
+
+	onEvent(t, client, "error", func(_ sobek.Value, args ...sobek.Value) (sobek.Value, error) {
+		errorMethods = append(errorMethods, args[0].ToObject(rt).Get("method").String())
+
+		return sobek.Undefined(), nil
+	})
+
+	err := runtime.EventLoop.Start(func() error {
+		_, err := client.connect(rt.ToValue(os.Getenv(broker.EnvBrokerAddress)), nil) //nolint:forbidigo // test reads the embedded broker address from env
+		require.NoError(t, err)
+
+		// Takes the only token, so the batch waits 200ms before sending its message.
+		require.NoError(t, client.publish("test/batch/throttled", rt.ToValue("first"), nil))
+
+		result, err := client.publishMany([]batchMessage{{Topic: "test/batch/throttled", Payload: rt.ToValue("second")}}, nil)
+		require.NoError(t, err)
+
+		// The duration starts when the first message is sent, after waiting for the rate limiter.
+		duration, _ := result.Export().(map[string]any)["duration"].(float64)
+		require.Less(t, duration, float64(150))
+
+		batch, err := client.publishManyAsync([]batchMessage{
+			{Topic: "test/batch/throttled/a", Payload: rt.ToValue("a")},
+			{Topic: "test/batch/throttled/b", Payload: rt.ToValue("b")},
+			{Topic: "test/batch/throttled/c", Payload: rt.ToValue("c")},
+		}, nil)
+		require.NoError(t, err)
+
+		// Replaces the connection after the first message was sent, while the second one is throttled.
+		go func() {
+			time.Sleep(300 * time.Millisecond)
+
+			assert.NoError(t, client.reconnect())
+		}()
+
+		require.NoError(t, rt.Set("batch", batch))
+		require.NoError(t, rt.Set("done", func() { require.NoError(t, client.end(nil)) }))
+
+		_, err = rt.RunString(`batch.then((r) => { errors = r.results.map((result) => result.error) }).finally(done)`)
+
+		return err
+	})
+
+	require.NoError(t, err)
+
+	runtime.EventLoop.WaitOnRegistered()
+
+	var errs []any
+
+	require.NoError(t, rt.ExportTo(rt.Get("errors"), &errs))
+	require.Len(t, errs, 3)
+	require.Nil(t, errs[0])
+	require.Contains(t, errs[1], errNotConnected.Error())
+	require.Contains(t, errs[2], errNotConnected.Error())
+
+	// The messages of the batch sent over another connection fail, and fire the error event like publish.
+	require.Equal(t, []string{"publish", "publish"}, errorMethods)
+}
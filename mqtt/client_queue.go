@@ -0,0 +1,210 @@
+package mqtt
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.k6.io/k6/v2/metrics"
+)
+
+const (
+	defaultInboundQueueSize = 100
+	overflowBlock           = "block"
+)
+
+// inboundQueueOptions configures the queue buffering received messages until the event loop handles them.
+type inboundQueueOptions struct {
+	Size     int
+	Overflow string
+}
+
+func (qo *inboundQueueOptions) validate() error {
+	switch {
+	case qo.Size < 0:
+		return fmt.Errorf("%w: inbound queue size must not be negative", errInvalidType)
+	case qo.Size == 0:
+		qo.Size = defaultInboundQueueSize
+	}
+
+	switch qo.Overflow {
+	case "":
+		qo.Overflow = overflowBlock
+	case overflowBlock, overflowDropOldest, overflowDropNewest:
+	default:
+		return fmt.Errorf("%w: %q", errInvalidOverflow, qo.Overflow)
+	}
+
+	return nil
+}
+
+// inboundMessage is a received message waiting to be passed to its handlers.
+type inboundMessage struct {
+	topic string
+	call  func() error
+}
+
+// inboundQueue decouples the receiving of messages from the event loop,
+// so a slow handler does not stall the connection.
+type inboundQueue struct {
+	opts *inboundQueueOptions
+
+	items  []*inboundMessage
+	notify chan struct{}
+
+	mu sync.Mutex
+}
+
+func newInboundQueue(opts *inboundQueueOptions) *inboundQueue {
+	return &inboundQueue{opts: opts, notify: make(chan struct{})}
+}
+
+// push adds the message to the queue, applying the overflow policy when it is full.
+// It returns the dropped message, if any, and the queue depth.
+func (q *inboundQueue) push(msg *inboundMessage, stop <-chan struct{}) (*inboundMessage, int) {
+	q.mu.Lock()
+
+	for len(q.items) >= q.opts.Size {
+		switch q.opts.Overflow {
+		case overflowDropNewest:
+			depth := len(q.items)
+			q.mu.Unlock()
+
+			return msg, depth
+		case overflowDropOldest:
+			dropped := q.items[0]
+			q.items = append(q.items[1:], msg)
+			depth := len(q.items)
+			q.mu.Unlock()
+
+			return dropped, depth
+		default:
+			notify := q.notify
+			q.mu.Unlock()
+
+			select {
+			case <-notify:
+			case <-stop:
+				return msg, 0
+			}
+
+			q.mu.Lock()
+		}
+	}
+
+	q.items = append(q.items, msg)
+	depth := len(q.items)
+
+	q.signal()
+	q.mu.Unlock()
+
+	return nil, depth
+}
+
+// pop waits for the next message. It returns the message and the remaining queue depth, or nil once stop is closed.
+func (q *inboundQueue) pop(stop <-chan struct{}) (*inboundMessage, int) {
+	for {
+		q.mu.Lock()
+
+		if len(q.items) != 0 {
+			msg := q.items[0]
+			q.items = q.items[1:]
+			depth := len(q.items)
+
+			q.signal()
+			q.mu.Unlock()
+
+			return msg, depth
+		}
+
+		notify := q.notify
+		q.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-stop:
+			return nil, 0
+		}
+	}
+}
+
+// signal wakes up all goroutines waiting for a change of the queue. It must be called with the lock held.
+func (q *inboundQueue) signal() {
+	close(q.notify)
+	q.notify = make(chan struct{})
+}
+
+// dispatchMessage queues the call of the handlers of a received message on the event loop,
+// through the inbound queue if configured.
+func (c *client) dispatchMessage(topic string, call func() error) {
+	if c.inbound == nil {
+		c.enqueue("message", call, nil)
+
+		return
+	}
+
+	dropped, depth := c.inbound.push(&inboundMessage{topic: topic, call: call}, c.stopped())
+
+	now := time.Now()
+
+	samples := metrics.Samples{c.depthSample(depth, now)}
+
+	if dropped != nil {
+		c.log.WithField("topic", dropped.topic).Debug("Inbound queue full, message dropped")
+
+		samples = append(samples, c.droppedSample(dropped.topic, now))
+	}
+
+	metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, samples)
+}
+
+// depthSample returns the sample of the inbound queue depth.
+func (c *client) depthSample(depth int, now time.Time) metrics.Sample {
+	return metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: c.metrics.mqttInboundQueueDepth,
+			Tags:   c.tags(),
+		},
+		Time:  now,
+		Value: float64(depth),
+	}
+}
+
+// droppedSample returns the sample counting a received message dropped because a buffer was full.
+func (c *client) droppedSample(topic string, now time.Time) metrics.Sample {
+	return metrics.Sample{
+		TimeSeries: metrics.TimeSeries{
+			Metric: c.metrics.mqttMessagesDropped,
+			Tags:   c.tags().With("topic", topic),
+		},
+		Time:  now,
+		Value: float64(1),
+	}
+}
+
+// pumpInbound passes the queued messages to the event loop until stop is closed.
+// The next message is only taken once the handlers of the previous one returned,
+// so the queue fills up while the handlers are slower than the incoming messages.
+// The queue depth is reported after each message taken too, so the gauge follows the queue as it drains.
+func (c *client) pumpInbound(stop chan struct{}) {
+	for {
+		msg, depth := c.inbound.pop(stop)
+		if msg == nil {
+			return
+		}
+
+		metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, metrics.Samples{c.depthSample(depth, time.Now())})
+
+		done := make(chan struct{})
+
+		if !c.enqueue("message", msg.call, done) {
+			return
+		}
+
+		select {
+		case <-done:
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package mqtt
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/grafana/sobek"
+	"github.com/grafana/xk6-mqtt/internal/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_inboundQueue_overflow(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		overflow string
+		dropped  string
+		want     []string
+	}{
+		{overflow: overflowDropOldest, dropped: "a", want: []string{"b", "c"}},
+		{overflow: overflowDropNewest, dropped: "c", want: []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.overflow, func(t *testing.T) {
+			t.Parallel()
+
+			opts := &inboundQueueOptions{Size: 2, Overflow: tt.overflow}
+
+			require.NoError(t, opts.validate())
+
+			q := newInboundQueue(opts)
+
+			for _, topic := range []string{"a", "b"} {
+				dropped, _ := q.push(&inboundMessage{topic: topic}, nil)
+				require.Nil(t, dropped)
+			}
+
+			dropped, depth := q.push(&inboundMessage{topic: "c"}, nil)
+			require.NotNil(t, dropped)
+			require.Equal(t, tt.dropped, dropped.topic)
+			require.Equal(t, 2, depth)
+
+			var got []string
+
+			for range 2 {
+				msg, _ := q.pop(nil)
+				got = append(got, msg.topic)
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_inboundQueue_block(t *testing.T) {
+	t.Parallel()
+
+	q := newInboundQueue(&inboundQueueOptions{Size: 1, Overflow: overflowBlock})
+
+	dropped, _ := q.push(&inboundMessage{topic: "a"}, nil)
+	require.Nil(t, dropped)
+
+	pushed := make(chan struct{})
+
+	go func() {
+		defer close(pushed)
+
+		dropped, _ := q.push(&inboundMessage{topic: "b"}, nil)
+		require.Nil(t, dropped)
+	}()
+
+	select {
+	case <-pushed:
+		require.Fail(t, "push should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	msg, depth := q.pop(nil)
+	require.Equal(t, "a", msg.topic)
+	require.Equal(t, 0, depth)
+
+	<-pushed
+
+	msg, _ = q.pop(nil)
+	require.Equal(t, "b", msg.topic)
+
+	stop := make(chan struct{})
+	close(stop)
+
+	msg, _ = q.pop(stop)
+	require.Nil(t, msg)
+}
+
+func Test_inboundQueueOptions_validate(t *testing.T) {
+	t.Parallel()
+
+	opts := new(inboundQueueOptions)
+
+	require.NoError(t, opts.validate())
+	require.Equal(t, defaultInboundQueueSize, opts.Size)
+	require.Equal(t, overflowBlock, opts.Overflow)
+
+	require.ErrorIs(t, (&inboundQueueOptions{Overflow: overflowError}).validate(), errInvalidOverflow)
+	require.ErrorIs(t, (&inboundQueueOptions{Size: -1}).validate(), errInvalidType)
+}
+
+func TestClientInboundQueueSlowHandler(t *testing.T) {
+	t.Parallel()
+
+	runtime := newTestRuntime(t)
+	mm := newMqttMetrics(runtime.VU)
+	logger := runtime.VU.InitEnv().Logger
+	state, samples := newTestVUStateWithSamples(t)
+
+	runtime.MoveToVUContext(state)
+
+	toValue := runtime.VU.Runtime().ToValue
+	topic := "test/inbound-queue/slow"
+
+	client := newTestClient(t, logger, runtime.VU, mm)
+	client.clientOpts.InboundQueue = &inboundQueueOptions{Size: 2, Overflow: overflowDropNewest}
+	client.inbound = newInboundQueue(client.clientOpts.InboundQueue)
+
+	// Started again to start the pump of the inbound queue too.
+	client.stopLoop()
+	client.startLoop()
+
+	handled := 0
+
+	onEvent(t, client, "connect", func(_ sobek.Value, _ ...sobek.Value) (sobek.Value, error) {
+		require.NoError(t, client.subscribe(toValue(topic), &subscribeOptions{Qos: 1}))
+
+		for range 10 {
+			require.NoError(t, client.publish(topic, toValue("message"), &publishOptions{Qos: 1}))
+		}
+
+		// Keeps the event loop busy until all messages arrived, like a slow handler would.
+		time.Sleep(300 * time.Millisecond)
+
+		return sobek.Undefined(), nil
+	})
+
+	onEvent(t, client, "message", func(_ sobek.Value, _ ...sobek.Value) (sobek.Value, error) {
+		handled++
+
+		time.Sleep(20 * time.Millisecond)
+
+		if handled == 3 {
+			require.NoError(t, client.end(nil))
+		}
+
+		return sobek.Undefined(), nil
+	})
+
+	err := runtime.EventLoop.Start(func() error {
+		_, err := client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), nil) //nolint:forbidigo // test reads the embedded broker address from env
+
+		return err
+	})
+
+	require.NoError(t, err)
+
+	runtime.EventLoop.WaitOnRegistered()
+
+	// One message is handed to the event loop, two wait in the queue and the others are dropped.
+	require.Equal(t, 3, handled)
+
+	var dropped, depth, lastDepth float64
+
+	for _, sample := range collectSamples(samples, nil) {
+		switch sample.Metric {
+		case mm.mqttMessagesDropped:
+			dropped += sample.Value
+		case mm.mqttInboundQueueDepth:
+			depth = max(depth, sample.Value)
+			lastDepth = sample.Value
+		}
+	}
+
+	require.InDelta(t, 7, dropped, 0)
+	require.InDelta(t, 2, depth, 0)
+
+	// The depth is reported again as the pump drains the queue.
+	require.InDelta(t, 0, lastDepth, 0)
+}
@@ -0,0 +1,75 @@
+package mqtt
+
+import (
+	"fmt"
+	"time"
+
+	"go.k6.io/k6/v2/metrics"
+	"golang.org/x/time/rate"
+)
+
+// rateOptions limits the rate of messages published by a client.
+type rateOptions struct {
+	MessagesPerSecond float64
+	Burst             int
+}
+
+func (ro *rateOptions) validate() error {
+	if ro.MessagesPerSecond <= 0 {
+		return fmt.Errorf("%w: rate messages_per_second must be positive", errInvalidType)
+	}
+
+	if ro.Burst < 0 {
+		return fmt.Errorf("%w: rate burst must not be negative", errInvalidType)
+	}
+
+	if ro.Burst == 0 {
+		ro.Burst = 1
+	}
+
+	return nil
+}
+
+func (ro *rateOptions) limiter() *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(ro.MessagesPerSecond), ro.Burst)
+}
+
+// throttle waits until the rate limiter of the client allows publishing the next message,
+// recording the time waited in the mqtt_publish_throttled_duration metric.
+// Called by the synchronous publish methods, it blocks the event loop while waiting.
+func (c *client) throttle(topic string, tags map[string]string) error {
+	if c.limiter == nil {
+		return nil
+	}
+
+	reservation := c.limiter.Reserve()
+
+	delay := reservation.Delay()
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-c.vu.Context().Done():
+		reservation.Cancel()
+
+		return c.vu.Context().Err()
+	}
+
+	metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, metrics.Samples{
+		metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.mqttPublishThrottledDuration,
+				Tags:   c.tagsForMethod("publish", tags, "topic", topic),
+			},
+			Time:  time.Now(),
+			Value: metrics.D(delay),
+		},
+	})
+
+	return nil
+}
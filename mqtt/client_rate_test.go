@@ -0,0 +1,69 @@
+package mqtt
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/grafana/xk6-mqtt/internal/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_rateOptions_validate(t *testing.T) {
+	t.Parallel()
+
+	opts := &rateOptions{MessagesPerSecond: 10}
+
+	require.NoError(t, opts.validate())
+	require.Equal(t, 1, opts.Burst)
+
+	require.ErrorIs(t, (&rateOptions{}).validate(), errInvalidType)
+	require.ErrorIs(t, (&rateOptions{MessagesPerSecond: 10, Burst: -1}).validate(), errInvalidType)
+}
+
+func TestClientPublishRate(t *testing.T) {
+	t.Parallel()
+
+	runtime := newTestRuntime(t)
+	mm := newMqttMetrics(runtime.VU)
+	logger := runtime.VU.InitEnv().Logger
+	state, samples := newTestVUStateWithSamples(t)
+
+	runtime.MoveToVUContext(state)
+
+	client := newTestClient(t, logger, runtime.VU, mm)
+	client.clientOpts.Rate = &rateOptions{MessagesPerSecond: 50, Burst: 2}
+
+	require.NoError(t, client.clientOpts.Rate.validate())
+
+	client.limiter = client.clientOpts.Rate.limiter()
+
+	toValue := runtime.VU.Runtime().ToValue
+
+	var elapsed time.Duration
+
+	err := runtime.EventLoop.Start(func() error {
+		_, err := client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), nil) //nolint:forbidigo // test reads the embedded broker address from env
+		require.NoError(t, err)
+
+		start := time.Now()
+
+		for range 5 {
+			require.NoError(t, client.publish("test/rate", toValue("paced"), nil))
+		}
+
+		elapsed = time.Since(start)
+
+		require.NoError(t, client.end(nil))
+
+		return nil
+	})
+
+	require.NoError(t, err)
+
+	runtime.EventLoop.WaitOnRegistered()
+
+	// The burst is sent at once, the remaining 3 messages are paced 20ms apart.
+	require.GreaterOrEqual(t, elapsed, 50*time.Millisecond)
+	require.Len(t, collectSamples(samples, mm.mqttPublishThrottledDuration), 3)
+}
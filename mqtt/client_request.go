@@ -0,0 +1,186 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/grafana/sobek"
+)
+
+const (
+	replyTopicPrefix      = "k6/replies/"
+	defaultRequestTimeout = 30 * time.Second
+)
+
+var errRequestTimeout = errors.New("request timed out")
+
+type requestOptions struct {
+	Qos      byte
+	Timeout  sobek.Value
+	Encoding string
+	Tags     map[string]string
+}
+
+func (ro *requestOptions) timeout() time.Duration {
+	if sobek.IsNumber(ro.Timeout) && ro.Timeout.ToInteger() > 0 {
+		return time.Millisecond * time.Duration(ro.Timeout.ToInteger())
+	}
+
+	return defaultRequestTimeout
+}
+
+// requestEnvelope wraps request and reply payloads on MQTT 3.1.1,
+// where response topic and correlation data properties are not available.
+type requestEnvelope struct {
+	ResponseTopic   string `json:"response_topic,omitempty"`
+	CorrelationData string `json:"correlation_data"`
+	Payload         []byte `json:"payload"`
+}
+
+func (c *client) request(topic string, message sobek.Value, opts *requestOptions) (*sobek.Promise, error) {
+	if !c.isConnected() {
+		return nil, errNotConnected
+	}
+
+	if opts == nil {
+		opts = new(requestOptions)
+	}
+
+	data, err := encodePayload(message, opts.Encoding, c.vu.Runtime())
+	if err != nil {
+		return nil, err
+	}
+
+	promise, resolve, reject := c.newPromise()
+
+	go func() {
+		reply, err := c.requestExecute(topic, data, opts)
+		if err != nil {
+			reject(err)
+
+			return
+		}
+
+		resolve(func(rt *sobek.Runtime) (any, error) {
+			if reply == nil {
+				return sobek.Undefined(), nil
+			}
+
+			return rt.NewArrayBuffer(reply), nil
+		})
+	}()
+
+	return promise, nil
+}
+
+func (c *client) requestExecute(topic string, data []byte, opts *requestOptions) ([]byte, error) {
+	if err := c.subscribeReplyTopic(opts.Qos); err != nil {
+		return nil, c.handleError(err, "subscribe", opts.Tags, "topic", c.replyTopic)
+	}
+
+	id := strconv.FormatUint(c.requestSeq.Add(1), 10)
+	replies := make(chan []byte, 1)
+
+	c.requests.Store(id, replies)
+	defer c.requests.Delete(id)
+
+	pubOpts := &publishOptions{Qos: opts.Qos, Tags: opts.Tags}
+
+	if c.supportsProperties() {
+		pubOpts.Properties = &publishProperties{ResponseTopic: c.replyTopic, CorrelationData: id}
+	} else {
+		envelope, err := json.Marshal(&requestEnvelope{ResponseTopic: c.replyTopic, CorrelationData: id, Payload: data})
+		if err != nil {
+			return nil, err
+		}
+
+		data = envelope
+	}
+
+	start := time.Now()
+
+	if err := c.publishExecute(topic, data, pubOpts); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(opts.timeout())
+	defer timer.Stop()
+
+	select {
+	case reply := <-replies:
+		c.addDurationMetrics(c.metrics.mqttRequestDuration, "request", time.Since(start), opts.Tags, "topic", topic)
+
+		return reply, nil
+	case <-timer.C:
+		return nil, c.handleError(errRequestTimeout, "request", opts.Tags, "topic", topic)
+	case <-c.vu.Context().Done():
+		return nil, c.vu.Context().Err()
+	}
+}
+
+func (c *client) subscribeReplyTopic(qos byte) error {
+	if c.replySubscribed.Load() {
+		return nil
+	}
+
+	c.mu.RLock()
+
+	if c.pahoClient == nil {
+		c.mu.RUnlock()
+
+		return errNotConnected
+	}
+
+	token := c.pahoClient.Subscribe(c.replyTopic, qos, nil)
+	c.mu.RUnlock()
+
+	if token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+
+	c.replySubscribed.Store(true)
+
+	return nil
+}
+
+func (c *client) handleReply(msg paho.Message, data []byte) {
+	var (
+		id      string
+		payload []byte
+	)
+
+	if mp, ok := msg.(messageWithProperties); ok && mp.Properties() != nil && mp.Properties().CorrelationData != nil {
+		id, payload = string(mp.Properties().CorrelationData), data
+	} else {
+		var envelope requestEnvelope
+
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			c.log.WithField("error", err).Warn("Ignoring malformed reply")
+
+			return
+		}
+
+		id, payload = envelope.CorrelationData, envelope.Payload
+	}
+
+	if payload == nil {
+		payload = []byte{}
+	}
+
+	r, ok := c.requests.Load(id)
+	if !ok {
+		c.log.WithField("correlation_data", id).Debug("Ignoring reply without pending request")
+
+		return
+	}
+
+	if replies, ok := r.(chan []byte); ok {
+		select {
+		case replies <- payload:
+		default:
+		}
+	}
+}
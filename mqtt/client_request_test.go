@@ -0,0 +1,99 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/grafana/sobek"
+	"github.com/grafana/xk6-mqtt/internal/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientRequestNotConnected(t *testing.T) {
+	t.Parallel()
+
+	runtime := newTestRuntime(t)
+	mm := newMqttMetrics(runtime.VU)
+	logger := runtime.VU.InitEnv().Logger
+
+	runtime.MoveToVUContext(newTestVUState(t))
+
+	client := newTestClient(t, logger, runtime.VU, mm)
+
+	// Ending the client while a request is pending leaves no paho client to subscribe with.
+	require.ErrorIs(t, client.subscribeReplyTopic(0), errNotConnected)
+
+	client.stopLoop()
+}
+
+func TestClientRequestDuration(t *testing.T) {
+	t.Parallel()
+
+	runtime := newTestRuntime(t)
+	mm := newMqttMetrics(runtime.VU)
+	logger := runtime.VU.InitEnv().Logger
+	state, samples := newTestVUStateWithSamples(t)
+
+	runtime.MoveToVUContext(state)
+
+	rt := runtime.VU.Runtime()
+	address := rt.ToValue(os.Getenv(broker.EnvBrokerAddress)) //nolint:forbidigo // test reads the embedded broker address from env
+	serviceTopic := "test/request/duration"
+
+	client := newTestClient(t, logger, runtime.VU, mm)
+	responder := newTestClient(t, logger, runtime.VU, mm)
+
+	// Echoes the MQTT 3.1.1 request envelope back to the reply topic.
+	onEvent(t, responder, "message", func(_ sobek.Value, args ...sobek.Value) (sobek.Value, error) {
+		data := args[1].Export().(sobek.ArrayBuffer).Bytes() //nolint:forcetypeassert
+
+		var envelope requestEnvelope
+
+		require.NoError(t, json.Unmarshal(data, &envelope))
+		require.NoError(t, responder.publish(envelope.ResponseTopic, rt.ToValue(string(data)), nil))
+
+		return sobek.Undefined(), nil
+	})
+
+	err := runtime.EventLoop.Start(func() error {
+		_, err := responder.connect(address, nil)
+		require.NoError(t, err)
+		require.NoError(t, responder.subscribe(rt.ToValue(serviceTopic), nil))
+
+		_, err = client.connect(address, nil)
+		require.NoError(t, err)
+
+		replied, err := client.request(serviceTopic, rt.ToValue("ping"), &requestOptions{Timeout: rt.ToValue(5000)})
+		require.NoError(t, err)
+
+		timedOut, err := client.request("nobody/listens", rt.ToValue("ping"), &requestOptions{Timeout: rt.ToValue(100)})
+		require.NoError(t, err)
+
+		require.NoError(t, rt.Set("replied", replied))
+		require.NoError(t, rt.Set("timedOut", timedOut))
+		require.NoError(t, rt.Set("done", func() {
+			require.NoError(t, client.end(nil))
+			require.NoError(t, responder.end(nil))
+		}))
+
+		_, err = rt.RunString(`Promise.allSettled([replied, timedOut]).then(done)`)
+
+		return err
+	})
+
+	require.NoError(t, err)
+
+	runtime.EventLoop.WaitOnRegistered()
+
+	// Only the request answered by a reply records its duration.
+	durations := collectSamples(samples, mm.mqttRequestDuration)
+
+	require.Len(t, durations, 1)
+
+	method, _ := durations[0].Tags.Get("method")
+	require.Equal(t, "request", method)
+
+	topic, _ := durations[0].Tags.Get("topic")
+	require.Equal(t, serviceTopic, topic)
+}
@@ -0,0 +1,139 @@
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho/session/state"
+	"github.com/eclipse/paho.golang/paho/store/file"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"go.k6.io/k6/v2/metrics"
+)
+
+const (
+	storeTypeMemory = "memory"
+	storeTypeFile   = "file"
+
+	// v3OutboundPrefix is the key prefix of outgoing messages in the paho MQTT 3.1.1 store.
+	v3OutboundPrefix = "o."
+)
+
+var errInvalidStore = errors.New("invalid session store")
+
+// storeOptions selects where the client keeps the in-flight QoS 1 and QoS 2 messages of its session.
+type storeOptions struct {
+	Type string
+	Dir  string
+}
+
+func (so *storeOptions) validate() error {
+	switch so.Type {
+	case "", storeTypeMemory:
+		so.Type = storeTypeMemory
+	case storeTypeFile:
+		if len(so.Dir) == 0 {
+			return fmt.Errorf("%w: dir is required for the file store", errInvalidStore)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported type %q", errInvalidStore, so.Type)
+	}
+
+	return nil
+}
+
+// sessionDir returns the directory of the session of the client with the given ID,
+// so clients sharing a store directory do not overwrite each other's messages.
+func (so *storeOptions) sessionDir(clientID string) (string, error) {
+	if len(clientID) == 0 {
+		return "", fmt.Errorf("%w: client_id is required for the file store", errInvalidStore)
+	}
+
+	name := url.PathEscape(clientID)
+	if strings.Trim(name, ".") == "" {
+		name = strings.ReplaceAll(name, ".", "%2E")
+	}
+
+	return filepath.Join(so.Dir, name), nil
+}
+
+// setupStore configures the file store of a new connection. It returns the number of
+// outgoing messages left in the store by a previous connection, which are sent again
+// when the session is resumed.
+func (c *client) setupStore(opts *paho.ClientOptions, v5 *pahoV5Client) (int, error) {
+	so := c.clientOpts.Store
+	if so == nil || so.Type != storeTypeFile {
+		return 0, nil
+	}
+
+	dir, err := so.sessionDir(opts.ClientID)
+	if err != nil {
+		return 0, err
+	}
+
+	if v5 != nil {
+		return v5.useFileStore(dir)
+	}
+
+	store := paho.NewFileStore(dir)
+
+	store.Open()
+
+	pending := 0
+
+	for _, key := range store.All() {
+		if strings.HasPrefix(key, v3OutboundPrefix) {
+			pending++
+		}
+	}
+
+	store.Close()
+
+	opts.SetStore(store)
+
+	return pending, nil
+}
+
+// useFileStore keeps the session state of the client in files under dir.
+// It returns the number of outgoing messages left in the store.
+func (c *pahoV5Client) useFileStore(dir string) (int, error) {
+	clientStore, err := file.New(dir, "client_", ".msg")
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", errInvalidStore, err.Error())
+	}
+
+	serverStore, err := file.New(dir, "server_", ".msg")
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", errInvalidStore, err.Error())
+	}
+
+	ids, err := clientStore.List()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", errInvalidStore, err.Error())
+	}
+
+	c.session = state.New(clientStore, serverStore)
+
+	return len(ids), nil
+}
+
+// addRedeliveredMetrics counts the messages sent or received again after a session was resumed.
+func (c *client) addRedeliveredMetrics(direction string, count int, tags *metrics.TagSet) {
+	if count == 0 {
+		return
+	}
+
+	metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, metrics.Samples{
+		metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: c.metrics.mqttMessagesRedelivered,
+				Tags:   tags.With("direction", direction),
+			},
+			Time:  time.Now(),
+			Value: float64(count),
+		},
+	})
+}
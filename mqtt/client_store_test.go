@@ -0,0 +1,187 @@
+package mqtt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+	"github.com/grafana/sobek"
+	"github.com/grafana/xk6-mqtt/internal/broker"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/v2/metrics"
+)
+
+func Test_storeOptions(t *testing.T) {
+	t.Parallel()
+
+	opts := new(storeOptions)
+
+	require.NoError(t, opts.validate())
+	require.Equal(t, storeTypeMemory, opts.Type)
+
+	require.ErrorIs(t, (&storeOptions{Type: storeTypeFile}).validate(), errInvalidStore)
+	require.ErrorIs(t, (&storeOptions{Type: "redis"}).validate(), errInvalidStore)
+
+	opts = &storeOptions{Type: storeTypeFile, Dir: "sessions"}
+
+	require.NoError(t, opts.validate())
+
+	dir, err := opts.sessionDir("devices/1")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("sessions", "devices%2F1"), dir)
+
+	dir, err = opts.sessionDir("..")
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join("sessions", "%2E%2E"), dir)
+
+	_, err = opts.sessionDir("")
+	require.ErrorIs(t, err, errInvalidStore)
+}
+
+func TestClientFileStoreResend(t *testing.T) {
+	t.Parallel()
+
+	runtime := newTestRuntime(t)
+	mm := newMqttMetrics(runtime.VU)
+	logger := runtime.VU.InitEnv().Logger
+	state, samples := newTestVUStateWithSamples(t)
+
+	runtime.MoveToVUContext(state)
+
+	storeDir := t.TempDir()
+	clientID := "k6-store-resend"
+	sessionDir := filepath.Join(storeDir, clientID)
+
+	// A QoS 1 message left in flight by a previous connection.
+	store := paho.NewFileStore(sessionDir)
+	store.Open()
+
+	publish, _ := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+	publish.TopicName = "test/store"
+	publish.Qos = 1
+	publish.MessageID = 1
+	publish.Payload = []byte("in flight")
+
+	store.Put(v3OutboundPrefix+"1", publish)
+	store.Close()
+
+	require.FileExists(t, filepath.Join(sessionDir, v3OutboundPrefix+"1.msg"))
+
+	client := newTestClient(t, logger, runtime.VU, mm)
+	client.clientOpts.ClientId = runtime.VU.Runtime().ToValue(clientID)
+	client.clientOpts.Store = &storeOptions{Type: storeTypeFile, Dir: storeDir}
+
+	toValue := runtime.VU.Runtime().ToValue
+
+	err := runtime.EventLoop.Start(func() error {
+		connectOpts := toValue(map[string]any{"clean_session": false})
+
+		_, err := client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), connectOpts) //nolint:forbidigo // test reads the embedded broker address from env
+		require.NoError(t, err)
+
+		// The message is removed from the store once the broker acknowledges it.
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(filepath.Join(sessionDir, v3OutboundPrefix+"1.msg"))
+
+			return os.IsNotExist(err)
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, client.end(nil))
+
+		return nil
+	})
+
+	require.NoError(t, err)
+
+	runtime.EventLoop.WaitOnRegistered()
+
+	redelivered := collectSamples(samples, mm.mqttMessagesRedelivered)
+
+	require.Len(t, redelivered, 1)
+	require.InDelta(t, 1, redelivered[0].Value, 0)
+
+	direction, _ := redelivered[0].Tags.Get("direction")
+	require.Equal(t, "sent", direction)
+}
+
+func TestClientSessionResumedAfterEnd(t *testing.T) {
+	t.Parallel()
+
+	runtime := newTestRuntime(t)
+	mm := newMqttMetrics(runtime.VU)
+	logger := runtime.VU.InitEnv().Logger
+	state, samples := newTestVUStateWithSamples(t)
+
+	runtime.MoveToVUContext(state)
+
+	toValue := runtime.VU.Runtime().ToValue
+	address := toValue(os.Getenv(broker.EnvBrokerAddress)) //nolint:forbidigo // test reads the embedded broker address from env
+	connectOpts := toValue(map[string]any{"clean_session": false})
+	topic := "test/session/resumed"
+
+	client := newTestClient(t, logger, runtime.VU, mm)
+	client.clientOpts.ClientId = toValue("k6-session-resumed")
+	client.clientOpts.InboundQueue = &inboundQueueOptions{Size: 10, Overflow: overflowBlock}
+	client.inbound = newInboundQueue(client.clientOpts.InboundQueue)
+
+	publisher := newTestClient(t, logger, runtime.VU, mm)
+
+	var received []string
+
+	onEvent(t, client, "message", func(_ sobek.Value, args ...sobek.Value) (sobek.Value, error) {
+		received = append(received, args[0].String())
+
+		require.NoError(t, client.end(nil))
+
+		return sobek.Undefined(), nil
+	})
+
+	err := runtime.EventLoop.Start(func() error {
+		_, err := client.connect(address, connectOpts)
+		require.NoError(t, err)
+		require.NoError(t, client.subscribe(toValue(topic), &subscribeOptions{Qos: 1}))
+		require.NoError(t, client.end(nil))
+
+		// Queued by the broker for the persistent session while the client is offline.
+		_, err = publisher.connect(address, nil)
+		require.NoError(t, err)
+		require.NoError(t, publisher.publish(topic, toValue("offline"), &publishOptions{Qos: 1}))
+		require.NoError(t, publisher.end(nil))
+
+		_, err = client.connect(address, connectOpts)
+		require.NoError(t, err)
+
+		return nil
+	})
+
+	require.NoError(t, err)
+
+	runtime.EventLoop.WaitOnRegistered()
+
+	require.Equal(t, []string{topic}, received)
+
+	// The message was passed through the inbound queue, whose pump was started again on connect.
+	collected := collectSamples(samples, nil)
+
+	var depths, redelivered []metrics.Sample
+
+	for _, sample := range collected {
+		switch sample.Metric {
+		case mm.mqttInboundQueueDepth:
+			depths = append(depths, sample)
+		case mm.mqttMessagesRedelivered:
+			redelivered = append(redelivered, sample)
+		}
+	}
+
+	// Reported when the message was queued, and again when the pump took it.
+	require.Len(t, depths, 2)
+	require.InDelta(t, 0, depths[1].Value, 0)
+	require.Len(t, redelivered, 1)
+
+	direction, _ := redelivered[0].Tags.Get("direction")
+	require.Equal(t, "received", direction)
+}
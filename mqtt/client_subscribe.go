@@ -3,6 +3,7 @@ package mqtt
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/grafana/sobek"
@@ -11,8 +12,10 @@ import (
 )
 
 type subscribeOptions struct {
-	Qos  byte
-	Tags map[string]string
+	Qos           byte
+	Handler       sobek.Callable
+	PayloadFormat string
+	Tags          map[string]string
 }
 
 func (c *client) subscribe(topic sobek.Value, opts *subscribeOptions) error {
@@ -53,21 +56,21 @@ func (c *client) subscribeAsync(topic sobek.Value, opts *subscribeOptions) (*sob
 func (c *client) subscribePrepare(
 	topic sobek.Value, opts *subscribeOptions,
 ) (map[string]byte, *subscribeOptions, error) {
-	if !c.isConnected() {
-		return nil, nil, errNotConnected
+	if opts == nil {
+		opts = new(subscribeOptions)
 	}
 
-	var qos byte
+	if !c.isConnected() {
+		return nil, opts, errNotConnected
+	}
 
-	if opts != nil {
-		qos = opts.Qos
-	} else {
-		opts = new(subscribeOptions)
+	if err := validatePayloadFormat(opts.PayloadFormat); err != nil {
+		return nil, opts, err
 	}
 
-	topics, err := asSubscribeTopics(topic, qos, c.vu.Runtime())
+	topics, err := asSubscribeTopics(topic, opts.Qos, c.vu.Runtime())
 	if err != nil {
-		return nil, nil, err
+		return nil, opts, err
 	}
 
 	return topics, opts, nil
@@ -79,12 +82,19 @@ func (c *client) subscribeExecute(topics map[string]byte, opts *subscribeOptions
 
 	c.log.Debug("Subscribing to MQTT topic(s)")
 
+	var callback paho.MessageHandler
+
+	if opts.Handler != nil || len(opts.PayloadFormat) != 0 {
+		callback = c.subscriptionHandler(opts.Handler, opts.PayloadFormat)
+	}
+
 	tokens := make(map[string]paho.Token)
+	start := time.Now()
 
 	for t, qos := range topics {
 		c.log.WithFields(logrus.Fields{"topic": t, "qos": qos}).Debug("Subscribing to topic")
 
-		token := c.pahoClient.Subscribe(t, qos, nil)
+		token := c.pahoClient.Subscribe(t, qos, callback)
 
 		tokens[t] = token
 	}
@@ -98,6 +108,13 @@ func (c *client) subscribeExecute(topics map[string]byte, opts *subscribeOptions
 			return nil
 		}
 
+		if group, _, ok, _ := parseSharedFilter(t); ok {
+			c.shareGroups.Store(t, group)
+		}
+
+		c.subscriptions.Store(t, &subscription{qos: topics[t], callback: callback})
+
+		c.addDurationMetrics(c.metrics.mqttSubscribeDuration, "subscribe", time.Since(start), opts.Tags, "topic", t)
 		c.addCallMetrics("subscribe", opts.Tags, "topic", t)
 	}
 
@@ -142,5 +159,67 @@ func asSubscribeTopics(value sobek.Value, qos byte, rt *sobek.Runtime) (map[stri
 		return nil, fmt.Errorf("%w: String or Array of String or Object expected", errInvalidType)
 	}
 
+	for topic := range topics {
+		if _, _, _, err := parseSharedFilter(topic); err != nil {
+			return nil, err
+		}
+	}
+
 	return topics, nil
 }
+
+// subscription is an active subscription, restored after reconnecting if resume_subs is set.
+type subscription struct {
+	qos      byte
+	callback paho.MessageHandler
+}
+
+// resumeSubscriptions subscribes again to all active subscriptions.
+// The lock is not held while subscribing, so a broker not acknowledging the subscriptions does not block connect or end.
+func (c *client) resumeSubscriptions() {
+	c.mu.RLock()
+	pahoClient := c.pahoClient
+	c.mu.RUnlock()
+
+	if pahoClient == nil {
+		return
+	}
+
+	tokens := make(map[string]paho.Token)
+
+	c.subscriptions.Range(func(k, v any) bool {
+		topic, _ := k.(string)
+		sub, _ := v.(*subscription)
+
+		c.log.WithField("topic", topic).Debug("Resuming subscription")
+
+		tokens[topic] = pahoClient.Subscribe(topic, sub.qos, sub.callback)
+
+		return true
+	})
+
+	for topic, token := range tokens {
+		if token.Wait() && token.Error() != nil {
+			_ = c.handleError(token.Error(), "subscribe", nil, "topic", topic)
+		}
+	}
+}
+
+// shareGroup returns the share group of the shared subscription matching the topic, if any.
+func (c *client) shareGroup(topic string) string {
+	var group string
+
+	c.shareGroups.Range(func(k, v any) bool {
+		filter, _ := k.(string)
+
+		if topicMatches(filter, topic) {
+			group, _ = v.(string)
+
+			return false
+		}
+
+		return true
+	})
+
+	return group
+}
@@ -0,0 +1,56 @@
+package mqtt
+
+import (
+	"os"
+	"testing"
+
+	"github.com/grafana/xk6-mqtt/internal/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientSubscribeDuration(t *testing.T) {
+	t.Parallel()
+
+	runtime := newTestRuntime(t)
+	mm := newMqttMetrics(runtime.VU)
+	logger := runtime.VU.InitEnv().Logger
+	state, samples := newTestVUStateWithSamples(t)
+
+	runtime.MoveToVUContext(state)
+
+	client := newTestClient(t, logger, runtime.VU, mm)
+
+	toValue := runtime.VU.Runtime().ToValue
+	topics := toValue([]string{"test/duration/1", "test/duration/2"})
+
+	err := runtime.EventLoop.Start(func() error {
+		_, err := client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), nil) //nolint:forbidigo // test reads the embedded broker address from env
+		require.NoError(t, err)
+		require.NoError(t, client.subscribe(topics, nil))
+		require.NoError(t, client.unsubscribe(topics, nil))
+		require.NoError(t, client.end(nil))
+
+		return nil
+	})
+
+	require.NoError(t, err)
+
+	runtime.EventLoop.WaitOnRegistered()
+
+	var connect, subscribe, unsubscribe int
+
+	for _, sample := range collectSamples(samples, nil) {
+		switch sample.Metric {
+		case mm.mqttConnectDuration:
+			connect++
+		case mm.mqttSubscribeDuration:
+			subscribe++
+		case mm.mqttUnsubscribeDuration:
+			unsubscribe++
+		}
+	}
+
+	require.Equal(t, 1, connect)
+	require.Equal(t, 2, subscribe)
+	require.Equal(t, 2, unsubscribe)
+}
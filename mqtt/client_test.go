@@ -3,7 +3,9 @@ package mqtt
 import (
 	"testing"
 
+	"github.com/grafana/sobek"
 	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
 	"go.k6.io/k6/v2/js/modules"
 )
 
@@ -14,7 +16,25 @@ func newTestClient(t *testing.T, logger logrus.FieldLogger, vu modules.VU, mm *m
 
 	client.clientOpts = new(clientOptions)
 
-	go client.loop()
+	client.startLoop()
 
 	return client
 }
+
+// onEvent registers a Go function as event listener of the client.
+func onEvent(t *testing.T, c *client, event string, fn sobek.Callable) {
+	t.Helper()
+
+	rt := c.vu.Runtime()
+
+	handler := rt.ToValue(func(call sobek.FunctionCall) sobek.Value {
+		v, err := fn(call.This, call.Arguments...)
+		if err != nil {
+			panic(rt.NewGoError(err))
+		}
+
+		return v
+	})
+
+	require.NoError(t, c.on(event, handler))
+}
@@ -0,0 +1,92 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+var errInvalidTLS = errors.New("invalid TLS options")
+
+//nolint:gochecknoglobals
+var tlsVersions = map[string]uint16{
+	"tls1.0": tls.VersionTLS10,
+	"tls1.1": tls.VersionTLS11,
+	"tls1.2": tls.VersionTLS12,
+	"tls1.3": tls.VersionTLS13,
+}
+
+// tlsOptions configures the TLS connection of a single client,
+// overriding the TLS settings of the k6 test.
+type tlsOptions struct {
+	Ca                 string
+	Cert               string
+	Key                string
+	ServerName         string
+	InsecureSkipVerify bool
+	MinVersion         string
+	Alpn               []string
+
+	rootCAs      *x509.CertPool
+	certificates []tls.Certificate
+	minVersion   uint16
+}
+
+// load parses the PEM encoded certificates and the minimum TLS version.
+func (to *tlsOptions) load() error {
+	if len(to.Ca) != 0 {
+		to.rootCAs = x509.NewCertPool()
+
+		if !to.rootCAs.AppendCertsFromPEM([]byte(to.Ca)) {
+			return fmt.Errorf("%w: no valid CA certificate found", errInvalidTLS)
+		}
+	}
+
+	if len(to.Cert) != 0 || len(to.Key) != 0 {
+		cert, err := tls.X509KeyPair([]byte(to.Cert), []byte(to.Key))
+		if err != nil {
+			return fmt.Errorf("%w: %s", errInvalidTLS, err.Error())
+		}
+
+		to.certificates = []tls.Certificate{cert}
+	}
+
+	if len(to.MinVersion) != 0 {
+		version, ok := tlsVersions[to.MinVersion]
+		if !ok {
+			return fmt.Errorf("%w: unsupported min_version %q", errInvalidTLS, to.MinVersion)
+		}
+
+		to.minVersion = version
+	}
+
+	return nil
+}
+
+// apply overrides the TLS settings of conf with the client specific ones.
+func (to *tlsOptions) apply(conf *tls.Config) {
+	if to.rootCAs != nil {
+		conf.RootCAs = to.rootCAs
+	}
+
+	if to.certificates != nil {
+		conf.Certificates = to.certificates
+	}
+
+	if len(to.ServerName) != 0 {
+		conf.ServerName = to.ServerName
+	}
+
+	if to.InsecureSkipVerify {
+		conf.InsecureSkipVerify = true
+	}
+
+	if to.minVersion != 0 {
+		conf.MinVersion = to.minVersion
+	}
+
+	if len(to.Alpn) != 0 {
+		conf.NextProtos = to.Alpn
+	}
+}
@@ -0,0 +1,110 @@
+package mqtt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCertificate(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "device-1"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return string(certPEM), string(keyPEM)
+}
+
+func Test_tlsOptions_apply(t *testing.T) {
+	t.Parallel()
+
+	cert, key := newTestCertificate(t)
+
+	to := &tlsOptions{
+		Ca:                 cert,
+		Cert:               cert,
+		Key:                key,
+		ServerName:         "broker.example.com",
+		InsecureSkipVerify: true,
+		MinVersion:         "tls1.3",
+		Alpn:               []string{"mqtt"},
+	}
+
+	require.NoError(t, to.load())
+
+	conf := &tls.Config{NextProtos: []string{"http/1.1"}} //nolint:gosec
+
+	to.apply(conf)
+
+	require.NotNil(t, conf.RootCAs)
+	require.Len(t, conf.Certificates, 1)
+	require.Equal(t, "broker.example.com", conf.ServerName)
+	require.True(t, conf.InsecureSkipVerify)
+	require.Equal(t, uint16(tls.VersionTLS13), conf.MinVersion)
+	require.Equal(t, []string{"mqtt"}, conf.NextProtos)
+}
+
+func Test_tlsOptions_apply_empty(t *testing.T) {
+	t.Parallel()
+
+	to := new(tlsOptions)
+
+	require.NoError(t, to.load())
+
+	conf := &tls.Config{ServerName: "broker", MinVersion: tls.VersionTLS12, NextProtos: []string{"http/1.1"}}
+
+	to.apply(conf)
+
+	require.Equal(t, "broker", conf.ServerName)
+	require.Equal(t, uint16(tls.VersionTLS12), conf.MinVersion)
+	require.Equal(t, []string{"http/1.1"}, conf.NextProtos)
+}
+
+func Test_tlsOptions_load_invalid(t *testing.T) {
+	t.Parallel()
+
+	cert, _ := newTestCertificate(t)
+
+	tests := map[string]*tlsOptions{
+		"ca":          {Ca: "not a certificate"},
+		"missing key": {Cert: cert},
+		"min version": {MinVersion: "ssl3"},
+	}
+
+	for name, to := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.ErrorIs(t, to.load(), errInvalidTLS)
+		})
+	}
+
+	require.ErrorIs(t, (&clientOptions{Tls: &tlsOptions{MinVersion: "ssl3"}}).validate(), errInvalidTLS)
+}
@@ -3,6 +3,7 @@ package mqtt
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/grafana/sobek"
@@ -50,17 +51,17 @@ func (c *client) unsubscribeAsync(topic sobek.Value, opts *unsubscribeOptions) (
 func (c *client) unsubscribePrepare(
 	topic sobek.Value, opts *unsubscribeOptions,
 ) ([]string, *unsubscribeOptions, error) {
-	if !c.isConnected() {
-		return nil, nil, errNotConnected
-	}
-
 	if opts == nil {
 		opts = new(unsubscribeOptions)
 	}
 
+	if !c.isConnected() {
+		return nil, opts, errNotConnected
+	}
+
 	topics, err := asUnsubscribeTopics(topic, c.vu.Runtime())
 	if err != nil {
-		return nil, nil, err
+		return nil, opts, err
 	}
 
 	return topics, opts, nil
@@ -70,6 +71,7 @@ func (c *client) unsubscribeExecute(topics []string, opts *unsubscribeOptions) e
 	c.log.Debug("Unsubscribing from MQTT topic(s)")
 
 	tokens := make(map[string]paho.Token)
+	start := time.Now()
 
 	for _, topic := range topics {
 		c.log.WithField("topic", topic).Debug("Unsubscribing from topic")
@@ -88,6 +90,10 @@ func (c *client) unsubscribeExecute(topics []string, opts *unsubscribeOptions) e
 			return nil
 		}
 
+		c.shareGroups.Delete(t)
+		c.subscriptions.Delete(t)
+
+		c.addDurationMetrics(c.metrics.mqttUnsubscribeDuration, "unsubscribe", time.Since(start), opts.Tags, "topic", t)
 		c.addCallMetrics("unsubscribe", opts.Tags, "topic", t)
 	}
 
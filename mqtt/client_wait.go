@@ -0,0 +1,127 @@
+package mqtt
+
+import (
+	"errors"
+	"time"
+
+	"github.com/grafana/sobek"
+)
+
+const defaultWaitTimeout = 30 * time.Second
+
+var errWaitTimeout = errors.New("no matching message received before timeout")
+
+type waitOptions struct {
+	Timeout       sobek.Value
+	PayloadFormat string
+	Tags          map[string]string
+}
+
+func (wo *waitOptions) timeout() time.Duration {
+	if sobek.IsNumber(wo.Timeout) && wo.Timeout.ToInteger() > 0 {
+		return time.Millisecond * time.Duration(wo.Timeout.ToInteger())
+	}
+
+	return defaultWaitTimeout
+}
+
+// receivedMessage is a received message passed to JavaScript as an object.
+type receivedMessage struct {
+	topic   string
+	payload []byte
+	packet  map[string]any
+}
+
+// exporter decodes the payload as format and returns a function converting the message on the event loop.
+func (m *receivedMessage) exporter(format string) (func(rt *sobek.Runtime) (any, error), error) {
+	payload, err := decodePayload(m.payload, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(rt *sobek.Runtime) (any, error) {
+		value, err := toValue(rt, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]any{"topic": m.topic, "payload": value, "packet": m.packet}, nil
+	}, nil
+}
+
+// messageWaiter waits for the next message matching a topic filter.
+type messageWaiter struct {
+	filter   string
+	messages chan *receivedMessage
+}
+
+func (c *client) waitForMessage(filter string, opts *waitOptions) (*sobek.Promise, error) {
+	if opts == nil {
+		opts = new(waitOptions)
+	}
+
+	if err := validateFilter(filter); err != nil {
+		return nil, err
+	}
+
+	if err := validatePayloadFormat(opts.PayloadFormat); err != nil {
+		return nil, err
+	}
+
+	format := c.payloadFormat(opts.PayloadFormat)
+
+	waiter := &messageWaiter{filter: filter, messages: make(chan *receivedMessage, 1)}
+	id := c.waiterSeq.Add(1)
+
+	// Registered before returning, so messages arriving right after the call are not missed.
+	c.waiters.Store(id, waiter)
+
+	promise, resolve, reject := c.newPromise()
+
+	// The promise is rejected even if an error listener was called, so awaiting it never returns without a message.
+	fail := func(err error) {
+		wrapped, _ := c.reportError(err, "waitForMessage", opts.Tags, "topic", filter)
+
+		reject(wrapped)
+	}
+
+	go func() {
+		defer c.waiters.Delete(id)
+
+		timer := time.NewTimer(opts.timeout())
+		defer timer.Stop()
+
+		select {
+		case msg := <-waiter.messages:
+			export, err := msg.exporter(format)
+			if err != nil {
+				fail(err)
+
+				return
+			}
+
+			resolve(export)
+		case <-timer.C:
+			fail(errWaitTimeout)
+		case <-c.vu.Context().Done():
+			reject(c.vu.Context().Err())
+		}
+	}()
+
+	return promise, nil
+}
+
+// notifyWaiters passes the message to every waiter with a matching filter.
+func (c *client) notifyWaiters(msg *receivedMessage) {
+	c.waiters.Range(func(k, v any) bool {
+		waiter, _ := v.(*messageWaiter)
+
+		if topicMatches(waiter.filter, msg.topic) {
+			if _, loaded := c.waiters.LoadAndDelete(k); loaded {
+				waiter.messages <- msg
+			}
+		}
+
+		return true
+	})
+}
@@ -10,6 +10,28 @@ const (
 	mqttMessagesReceived = "mqtt_messages_received"
 	mqttErrors           = "mqtt_errors"
 	mqttCalls            = "mqtt_calls"
+	mqttRequestDuration  = "mqtt_request_duration"
+	mqttPublishDuration  = "mqtt_publish_duration"
+
+	mqttPublishBatchDuration     = "mqtt_publish_batch_duration"
+	mqttPublishThrottledDuration = "mqtt_publish_throttled_duration"
+
+	mqttConnectDuration     = "mqtt_connect_duration"
+	mqttSubscribeDuration   = "mqtt_subscribe_duration"
+	mqttUnsubscribeDuration = "mqtt_unsubscribe_duration"
+	mqttMessageLatency      = "mqtt_message_latency"
+
+	mqttMessagesLost       = "mqtt_messages_lost"
+	mqttMessagesDuplicated = "mqtt_messages_duplicated"
+	mqttMessagesOutOfOrder = "mqtt_messages_out_of_order"
+
+	mqttReconnects     = "mqtt_reconnects"
+	mqttConnectionLost = "mqtt_connection_lost"
+
+	mqttMessagesDropped   = "mqtt_messages_dropped"
+	mqttInboundQueueDepth = "mqtt_inbound_queue_depth"
+
+	mqttMessagesRedelivered = "mqtt_messages_redelivered"
 )
 
 type mqttMetrics struct {
@@ -19,6 +41,28 @@ type mqttMetrics struct {
 	mqttMessagesReceived *metrics.Metric
 	mqttErrors           *metrics.Metric
 	mqttCalls            *metrics.Metric
+	mqttRequestDuration  *metrics.Metric
+	mqttPublishDuration  *metrics.Metric
+
+	mqttPublishBatchDuration     *metrics.Metric
+	mqttPublishThrottledDuration *metrics.Metric
+
+	mqttConnectDuration     *metrics.Metric
+	mqttSubscribeDuration   *metrics.Metric
+	mqttUnsubscribeDuration *metrics.Metric
+	mqttMessageLatency      *metrics.Metric
+
+	mqttMessagesLost       *metrics.Metric
+	mqttMessagesDuplicated *metrics.Metric
+	mqttMessagesOutOfOrder *metrics.Metric
+
+	mqttReconnects     *metrics.Metric
+	mqttConnectionLost *metrics.Metric
+
+	mqttMessagesDropped   *metrics.Metric
+	mqttInboundQueueDepth *metrics.Metric
+
+	mqttMessagesRedelivered *metrics.Metric
 }
 
 func newMqttMetrics(vu modules.VU) *mqttMetrics {
@@ -29,5 +73,27 @@ func newMqttMetrics(vu modules.VU) *mqttMetrics {
 		mqttMessagesReceived: vu.InitEnv().Registry.MustNewMetric(mqttMessagesReceived, metrics.Counter),
 		mqttErrors:           vu.InitEnv().Registry.MustNewMetric(mqttErrors, metrics.Counter),
 		mqttCalls:            vu.InitEnv().Registry.MustNewMetric(mqttCalls, metrics.Counter),
+		mqttRequestDuration:  vu.InitEnv().Registry.MustNewMetric(mqttRequestDuration, metrics.Trend, metrics.Time),
+		mqttPublishDuration:  vu.InitEnv().Registry.MustNewMetric(mqttPublishDuration, metrics.Trend, metrics.Time),
+
+		mqttPublishBatchDuration:     vu.InitEnv().Registry.MustNewMetric(mqttPublishBatchDuration, metrics.Trend, metrics.Time),
+		mqttPublishThrottledDuration: vu.InitEnv().Registry.MustNewMetric(mqttPublishThrottledDuration, metrics.Trend, metrics.Time),
+
+		mqttConnectDuration:     vu.InitEnv().Registry.MustNewMetric(mqttConnectDuration, metrics.Trend, metrics.Time),
+		mqttSubscribeDuration:   vu.InitEnv().Registry.MustNewMetric(mqttSubscribeDuration, metrics.Trend, metrics.Time),
+		mqttUnsubscribeDuration: vu.InitEnv().Registry.MustNewMetric(mqttUnsubscribeDuration, metrics.Trend, metrics.Time),
+		mqttMessageLatency:      vu.InitEnv().Registry.MustNewMetric(mqttMessageLatency, metrics.Trend, metrics.Time),
+
+		mqttMessagesLost:       vu.InitEnv().Registry.MustNewMetric(mqttMessagesLost, metrics.Counter),
+		mqttMessagesDuplicated: vu.InitEnv().Registry.MustNewMetric(mqttMessagesDuplicated, metrics.Counter),
+		mqttMessagesOutOfOrder: vu.InitEnv().Registry.MustNewMetric(mqttMessagesOutOfOrder, metrics.Counter),
+
+		mqttReconnects:     vu.InitEnv().Registry.MustNewMetric(mqttReconnects, metrics.Counter),
+		mqttConnectionLost: vu.InitEnv().Registry.MustNewMetric(mqttConnectionLost, metrics.Counter),
+
+		mqttMessagesDropped:   vu.InitEnv().Registry.MustNewMetric(mqttMessagesDropped, metrics.Counter),
+		mqttInboundQueueDepth: vu.InitEnv().Registry.MustNewMetric(mqttInboundQueueDepth, metrics.Gauge),
+
+		mqttMessagesRedelivered: vu.InitEnv().Registry.MustNewMetric(mqttMessagesRedelivered, metrics.Counter),
 	}
 }
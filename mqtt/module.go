@@ -11,14 +11,17 @@ const ImportPath = "k6/x/mqtt"
 
 // New creates a new MQTT module.
 func New() modules.Module {
-	return new(rootModule)
+	return &rootModule{clientIDs: newClientIDRegistry()}
 }
 
-type rootModule struct{}
+type rootModule struct {
+	clientIDs *clientIDRegistry
+}
 
-func (*rootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+func (root *rootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 	return &module{
-		vu: vu,
+		vu:        vu,
+		clientIDs: root.clientIDs,
 		log: vu.
 			InitEnv().
 			Logger.
@@ -28,15 +31,17 @@ func (*rootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 }
 
 type module struct {
-	vu      modules.VU
-	log     logrus.FieldLogger
-	metrics *mqttMetrics
+	vu        modules.VU
+	log       logrus.FieldLogger
+	metrics   *mqttMetrics
+	clientIDs *clientIDRegistry
 }
 
 func (m *module) Exports() modules.Exports {
 	return modules.Exports{
 		Named: map[string]any{
-			"Client": m.client,
+			"Client":     m.client,
+			"ClientPool": m.clientPool,
 		},
 	}
 }
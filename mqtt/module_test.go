@@ -66,7 +66,7 @@ func newTestRuntime(t *testing.T) *modulestest.Runtime {
 
 	err := runtime.SetupModuleSystem(
 		map[string]any{
-			ImportPath:    new(rootModule),
+			ImportPath:    New(),
 			"k6/x/assert": newAssertRoot(t),
 		},
 		nil,
@@ -87,6 +87,14 @@ func newTestRuntime(t *testing.T) *modulestest.Runtime {
 func newTestVUState(t *testing.T) *lib.State {
 	t.Helper()
 
+	state, _ := newTestVUStateWithSamples(t)
+
+	return state
+}
+
+func newTestVUStateWithSamples(t *testing.T) (*lib.State, chan metrics.SampleContainer) {
+	t.Helper()
+
 	samples := make(chan metrics.SampleContainer, 1000)
 
 	t.Cleanup(func() {
@@ -119,5 +127,20 @@ func newTestVUState(t *testing.T) *lib.State {
 		Tags:           lib.NewVUStateTags(registry.RootTagSet()),
 		Logger:         logger,
 		Dialer:         dialer,
+	}, samples
+}
+
+// collectSamples drains the samples channel, returning the samples of the given metric (or all samples if nil).
+func collectSamples(samples chan metrics.SampleContainer, metric *metrics.Metric) []metrics.Sample {
+	var collected []metrics.Sample
+
+	for len(samples) > 0 {
+		for _, sample := range (<-samples).GetSamples() {
+			if metric == nil || sample.Metric == metric {
+				collected = append(collected, sample)
+			}
+		}
 	}
+
+	return collected
 }
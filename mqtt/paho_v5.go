@@ -0,0 +1,547 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	paho5 "github.com/eclipse/paho.golang/paho"
+	"github.com/eclipse/paho.golang/paho/session/state"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	protocolVersion31  = 3
+	protocolVersion311 = 4
+	protocolVersion5   = 5
+
+	disconnectTimeout     = 5 * time.Second
+	connectionLostTimeout = time.Second
+)
+
+var (
+	errConnectionDown         = errors.New("connection down")
+	errPropertiesNotSupported = errors.New("message properties require MQTT v5")
+	errServerDisconnect       = errors.New("server requested disconnect")
+)
+
+// mqttClient is the subset of paho.Client used by the extension.
+// It is implemented by paho.Client for MQTT 3.1/3.1.1 and by pahoV5Client for MQTT 5.
+type mqttClient interface {
+	IsConnected() bool
+	Connect() paho.Token
+	Disconnect(quiesce uint)
+	Publish(topic string, qos byte, retained bool, payload any) paho.Token
+	Subscribe(topic string, qos byte, callback paho.MessageHandler) paho.Token
+	Unsubscribe(topics ...string) paho.Token
+	OptionsReader() paho.ClientOptionsReader
+}
+
+// propertiesPublisher is implemented by clients able to publish messages with MQTT 5 properties.
+type propertiesPublisher interface {
+	PublishWithProperties(
+		topic string, qos byte, retained bool, payload any, props *paho5.PublishProperties,
+	) paho.Token
+}
+
+// messageWithProperties is implemented by received messages carrying MQTT 5 properties.
+type messageWithProperties interface {
+	Properties() *paho5.PublishProperties
+}
+
+const (
+	statusDisconnected int32 = iota
+	statusConnecting
+	statusConnected
+	statusReconnecting
+)
+
+// pahoV5Client drives an autopaho connection manager using the options
+// collected in a paho.ClientOptions, so MQTT 5 connections are configured
+// exactly like MQTT 3.1.1 ones.
+type pahoV5Client struct {
+	opts *paho.ClientOptions
+
+	cm     *autopaho.ConnectionManager
+	ctx    context.Context //nolint:containedctx
+	cancel context.CancelFunc
+
+	status       atomic.Int32
+	wasConnected atomic.Bool
+
+	// connack holds the CONNACK of the latest connection.
+	connack atomic.Pointer[connack]
+
+	// routes holds the per-subscription message handlers by topic filter.
+	routes sync.Map
+
+	// session keeps the session state, in memory if nil.
+	session *state.State
+
+	mu sync.RWMutex
+}
+
+var (
+	_ mqttClient          = (*pahoV5Client)(nil)
+	_ propertiesPublisher = (*pahoV5Client)(nil)
+	_ connackReader       = (*pahoV5Client)(nil)
+)
+
+func newPahoV5Client(opts *paho.ClientOptions) *pahoV5Client {
+	opts.ProtocolVersion = protocolVersion5
+
+	return &pahoV5Client{opts: opts}
+}
+
+func (c *pahoV5Client) IsConnected() bool {
+	switch c.status.Load() {
+	case statusConnected:
+		return true
+	case statusReconnecting:
+		return c.opts.AutoReconnect
+	case statusConnecting:
+		return c.opts.ConnectRetry
+	default:
+		return false
+	}
+}
+
+func (c *pahoV5Client) OptionsReader() paho.ClientOptionsReader {
+	return paho.NewOptionsReader(c.opts)
+}
+
+func (c *pahoV5Client) lastConnack() *connack {
+	return c.connack.Load()
+}
+
+func (c *pahoV5Client) Connect() paho.Token {
+	token := &connectToken{asyncToken: newAsyncToken()}
+
+	var (
+		once     sync.Once
+		failures atomic.Int32
+	)
+
+	complete := func(err error) { once.Do(func() { token.complete(c.connack.Load(), err) }) }
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	cfg := c.config()
+
+	// Connection errors are reported asynchronously, so the reason of a lost connection is passed on through a channel.
+	lost := make(chan error, 1)
+
+	reportLost := func(err error) {
+		select {
+		case lost <- err:
+		default:
+		}
+	}
+
+	cfg.OnClientError = reportLost
+	cfg.OnServerDisconnect = func(d *paho5.Disconnect) {
+		reportLost(fmt.Errorf("%w (reason code: %d)", errServerDisconnect, d.ReasonCode))
+	}
+
+	cfg.OnConnectionUp = func(_ *autopaho.ConnectionManager, ack *paho5.Connack) {
+		c.connack.Store(&connack{sessionPresent: ack.SessionPresent, returnCode: ack.ReasonCode})
+		c.status.Store(statusConnected)
+		c.wasConnected.Store(true)
+
+		select {
+		case <-lost:
+		default:
+		}
+
+		complete(nil)
+
+		if c.opts.OnConnect != nil {
+			go c.opts.OnConnect(nil)
+		}
+	}
+
+	cfg.OnConnectError = func(err error) {
+		if c.wasConnected.Load() {
+			// Like paho does for MQTT 3.1.1, every reconnect attempt is reported.
+			if c.status.Load() == statusReconnecting && c.opts.OnReconnecting != nil {
+				go c.opts.OnReconnecting(nil, c.opts)
+			}
+
+			return
+		}
+
+		if c.opts.ConnectRetry {
+			return
+		}
+
+		// Without retry every server is tried only once, like paho does for MQTT 3.1.1.
+		if int(failures.Add(1)) >= len(cfg.ServerUrls) {
+			c.status.Store(statusDisconnected)
+			cancel()
+			complete(err)
+		}
+	}
+
+	cfg.OnConnectionDown = func() bool {
+		if c.opts.OnConnectionLost != nil {
+			go c.connectionLost(lost)
+		}
+
+		if !c.opts.AutoReconnect {
+			c.status.Store(statusDisconnected)
+
+			return false
+		}
+
+		c.status.Store(statusReconnecting)
+
+		if c.opts.OnReconnecting != nil {
+			go c.opts.OnReconnecting(nil, c.opts)
+		}
+
+		return true
+	}
+
+	c.status.Store(statusConnecting)
+
+	// Operations wait on the lock, so they never observe a completed connect token without the manager.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cm, err := autopaho.NewConnection(ctx, cfg)
+	if err != nil {
+		c.status.Store(statusDisconnected)
+		cancel()
+		complete(err)
+
+		return token
+	}
+
+	c.cm = cm
+	c.ctx = ctx
+	c.cancel = cancel
+
+	return token
+}
+
+// connectionLost calls the connection lost handler with the error that caused the connection to drop.
+func (c *pahoV5Client) connectionLost(lost <-chan error) {
+	timer := time.NewTimer(connectionLostTimeout)
+	defer timer.Stop()
+
+	var err error
+
+	select {
+	case err = <-lost:
+	case <-timer.C:
+		err = errConnectionDown
+	}
+
+	c.opts.OnConnectionLost(nil, err)
+}
+
+func (c *pahoV5Client) Disconnect(quiesce uint) {
+	c.mu.Lock()
+	cm, cancel := c.cm, c.cancel
+	c.cm = nil
+	c.mu.Unlock()
+
+	if cm == nil {
+		return
+	}
+
+	c.status.Store(statusDisconnected)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Duration(quiesce)*time.Millisecond+disconnectTimeout)
+	defer done()
+
+	_ = cm.Disconnect(ctx)
+
+	cancel()
+}
+
+func (c *pahoV5Client) Publish(topic string, qos byte, retained bool, payload any) paho.Token {
+	return c.PublishWithProperties(topic, qos, retained, payload, nil)
+}
+
+func (c *pahoV5Client) PublishWithProperties(
+	topic string, qos byte, retained bool, payload any, props *paho5.PublishProperties,
+) paho.Token {
+	var data []byte
+
+	switch p := payload.(type) {
+	case []byte:
+		data = p
+	case string:
+		data = []byte(p)
+	default:
+		return completedToken(errInvalidType)
+	}
+
+	pub := &paho5.Publish{
+		Topic:      topic,
+		QoS:        qos,
+		Retain:     retained,
+		Payload:    data,
+		Properties: props,
+	}
+
+	return c.run(func(ctx context.Context, cm *autopaho.ConnectionManager) error {
+		_, err := cm.Publish(ctx, pub)
+
+		return err
+	})
+}
+
+func (c *pahoV5Client) Subscribe(topic string, qos byte, callback paho.MessageHandler) paho.Token {
+	if callback != nil {
+		c.routes.Store(topic, callback)
+	}
+
+	sub := &paho5.Subscribe{
+		Subscriptions: []paho5.SubscribeOptions{{Topic: topic, QoS: qos}},
+	}
+
+	return c.run(func(ctx context.Context, cm *autopaho.ConnectionManager) error {
+		_, err := cm.Subscribe(ctx, sub)
+
+		return err
+	})
+}
+
+func (c *pahoV5Client) Unsubscribe(topics ...string) paho.Token {
+	for _, topic := range topics {
+		c.routes.Delete(topic)
+	}
+
+	unsub := &paho5.Unsubscribe{Topics: topics}
+
+	return c.run(func(ctx context.Context, cm *autopaho.ConnectionManager) error {
+		_, err := cm.Unsubscribe(ctx, unsub)
+
+		return err
+	})
+}
+
+func (c *pahoV5Client) run(fn func(context.Context, *autopaho.ConnectionManager) error) paho.Token {
+	c.mu.RLock()
+	cm, ctx := c.cm, c.ctx
+	c.mu.RUnlock()
+
+	if cm == nil {
+		return completedToken(errConnectionDown)
+	}
+
+	token := newAsyncToken()
+
+	go func() {
+		token.complete(fn(ctx, cm))
+	}()
+
+	return token
+}
+
+func (c *pahoV5Client) config() autopaho.ClientConfig {
+	opts := c.opts
+
+	servers := make([]*url.URL, 0, len(opts.Servers))
+	for _, server := range opts.Servers {
+		u := *server
+		servers = append(servers, &u)
+	}
+
+	cfg := autopaho.ClientConfig{
+		ServerUrls:                    servers,
+		TlsCfg:                        opts.TLSConfig,
+		KeepAlive:                     uint16(min(max(opts.KeepAlive, 0), math.MaxUint16)), //nolint:gosec
+		CleanStartOnInitialConnection: opts.CleanSession,
+		ConnectTimeout:                opts.ConnectTimeout,
+		ReconnectBackoff:              c.backoff,
+		ConnectUsername:               opts.Username,
+		ConnectPassword:               []byte(opts.Password),
+	}
+
+	if !opts.CleanSession {
+		// MQTT 3.1.1 sessions without clean session never expire.
+		cfg.SessionExpiryInterval = math.MaxUint32
+	}
+
+	if opts.WillEnabled {
+		cfg.WillMessage = &paho5.WillMessage{
+			Topic:   opts.WillTopic,
+			Payload: opts.WillPayload,
+			QoS:     opts.WillQos,
+			Retain:  opts.WillRetained,
+		}
+	}
+
+	if opts.CredentialsProvider != nil {
+		cfg.ConnectPacketBuilder = func(cp *paho5.Connect, _ *url.URL) (*paho5.Connect, error) {
+			username, password := opts.CredentialsProvider()
+
+			cp.Username, cp.UsernameFlag = username, len(username) > 0
+			cp.Password, cp.PasswordFlag = []byte(password), len(password) > 0
+
+			return cp, nil
+		}
+	}
+
+	cfg.ClientID = opts.ClientID
+
+	if c.session != nil {
+		cfg.Session = c.session
+	}
+	cfg.OnPublishReceived = []func(paho5.PublishReceived) (bool, error){c.publishReceived}
+
+	return cfg
+}
+
+// backoff mirrors the paho MQTT 3.1.1 client: a fixed interval while the
+// initial connection is retried, then doubling delays capped by the max reconnect interval.
+func (c *pahoV5Client) backoff(attempt int) time.Duration {
+	if attempt <= 0 {
+		return 0
+	}
+
+	if !c.wasConnected.Load() {
+		return c.opts.ConnectRetryInterval
+	}
+
+	delay := time.Second << min(attempt-1, 30) //nolint:mnd
+
+	return min(delay, c.opts.MaxReconnectInterval)
+}
+
+// publishReceived dispatches a received message like the paho MQTT 3.1.1 router: to the handlers
+// of all matching subscriptions, or to the default handler if no subscription has a handler.
+func (c *pahoV5Client) publishReceived(pr paho5.PublishReceived) (bool, error) {
+	msg := &pahoV5Message{publish: pr.Packet}
+	routed := false
+
+	c.routes.Range(func(k, v any) bool {
+		filter, _ := k.(string)
+
+		if handler, ok := v.(paho.MessageHandler); ok && topicMatches(filter, msg.Topic()) {
+			handler(nil, msg)
+
+			routed = true
+		}
+
+		return true
+	})
+
+	if !routed && c.opts.DefaultPublishHandler != nil {
+		c.opts.DefaultPublishHandler(nil, msg)
+	}
+
+	return true, nil
+}
+
+// pahoV5Message exposes a received MQTT 5 PUBLISH packet as a paho.Message.
+type pahoV5Message struct {
+	publish *paho5.Publish
+}
+
+var (
+	_ paho.Message          = (*pahoV5Message)(nil)
+	_ messageWithProperties = (*pahoV5Message)(nil)
+)
+
+func (m *pahoV5Message) Duplicate() bool   { return m.publish.Duplicate() }
+func (m *pahoV5Message) Qos() byte         { return m.publish.QoS }
+func (m *pahoV5Message) Retained() bool    { return m.publish.Retain }
+func (m *pahoV5Message) Topic() string     { return m.publish.Topic }
+func (m *pahoV5Message) MessageID() uint16 { return m.publish.PacketID }
+func (m *pahoV5Message) Payload() []byte   { return m.publish.Payload }
+func (m *pahoV5Message) Ack()              {}
+
+func (m *pahoV5Message) Properties() *paho5.PublishProperties {
+	return m.publish.Properties
+}
+
+// asyncToken is a paho.Token completed by a background operation.
+type asyncToken struct {
+	done chan struct{}
+	err  error
+}
+
+var _ paho.Token = (*asyncToken)(nil)
+
+func newAsyncToken() *asyncToken {
+	return &asyncToken{done: make(chan struct{})}
+}
+
+func completedToken(err error) *asyncToken {
+	t := newAsyncToken()
+	t.complete(err)
+
+	return t
+}
+
+func (t *asyncToken) complete(err error) {
+	t.err = err
+	close(t.done)
+}
+
+func (t *asyncToken) Wait() bool {
+	<-t.done
+
+	return true
+}
+
+func (t *asyncToken) WaitTimeout(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-t.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (t *asyncToken) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *asyncToken) Error() error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+// connectToken is the token of a connection, carrying the CONNACK of the broker once completed.
+type connectToken struct {
+	*asyncToken
+
+	connack *connack
+}
+
+var _ connackToken = (*connectToken)(nil)
+
+func (t *connectToken) complete(ack *connack, err error) {
+	t.connack = ack
+	t.asyncToken.complete(err)
+}
+
+func (t *connectToken) SessionPresent() bool {
+	return t.connack != nil && t.connack.sessionPresent
+}
+
+func (t *connectToken) ReturnCode() byte {
+	if t.connack == nil {
+		return 0
+	}
+
+	return t.connack.returnCode
+}
@@ -0,0 +1,192 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/grafana/sobek"
+)
+
+const (
+	payloadFormatBinary = "binary"
+	payloadFormatString = "string"
+	payloadFormatJSON   = "json"
+
+	payloadEncodingJSON = "json"
+)
+
+var (
+	errInvalidPayloadFormat   = errors.New("invalid payload format")
+	errInvalidPayloadEncoding = errors.New("invalid payload encoding")
+)
+
+// encodePayload converts a payload passed to publish to bytes. Strings are UTF-8 encoded,
+// ArrayBuffers and views of them are used without copying, and objects are serialized to JSON.
+// With the json encoding, any value is serialized to JSON.
+func encodePayload(input sobek.Value, encoding string, runtime *sobek.Runtime) ([]byte, error) {
+	switch encoding {
+	case "":
+	case payloadEncodingJSON:
+		return stringifyJSON(input, runtime)
+	default:
+		return nil, fmt.Errorf("%w: %q", errInvalidPayloadEncoding, encoding)
+	}
+
+	var data []byte
+
+	switch {
+	case input.ExportType() == reflect.TypeFor[string]():
+		var str string
+
+		if err := runtime.ExportTo(input, &str); err != nil {
+			return nil, err
+		}
+
+		data = []byte(str)
+
+	case input.ExportType() == reflect.TypeFor[sobek.ArrayBuffer](),
+		input.ExportType() == reflect.TypeFor[[]byte](),
+		isArrayBufferView(input, runtime):
+		if err := runtime.ExportTo(input, &data); err != nil {
+			return nil, err
+		}
+
+	case isObject(input):
+		return stringifyJSON(input, runtime)
+
+	default:
+		return nil, fmt.Errorf("%w: String, ArrayBuffer, TypedArray, DataView or Object expected", errInvalidType)
+	}
+
+	return data, nil
+}
+
+// isArrayBufferView reports whether value is a TypedArray or a DataView.
+func isArrayBufferView(value sobek.Value, runtime *sobek.Runtime) bool {
+	if _, ok := value.(*sobek.Object); !ok {
+		return false
+	}
+
+	isView, ok := sobek.AssertFunction(runtime.Get("ArrayBuffer").ToObject(runtime).Get("isView"))
+	if !ok {
+		return false
+	}
+
+	result, err := isView(sobek.Undefined(), value)
+
+	return err == nil && result.ToBoolean()
+}
+
+// isObject reports whether value is a plain object or an array.
+func isObject(value sobek.Value) bool {
+	switch value.ExportType() {
+	case reflect.TypeFor[map[string]any](), reflect.TypeFor[[]any]():
+		return true
+	default:
+		return false
+	}
+}
+
+func stringifyJSON(value sobek.Value, runtime *sobek.Runtime) ([]byte, error) {
+	stringify, ok := sobek.AssertFunction(runtime.Get("JSON").ToObject(runtime).Get("stringify"))
+	if !ok {
+		return nil, fmt.Errorf("%w: JSON.stringify is not a function", errInvalidType)
+	}
+
+	result, err := stringify(sobek.Undefined(), value)
+	if err != nil {
+		return nil, err
+	}
+
+	if sobek.IsUndefined(result) {
+		return nil, fmt.Errorf("%w: value cannot be serialized to JSON", errInvalidType)
+	}
+
+	return []byte(result.String()), nil
+}
+
+func validatePayloadFormat(format string) error {
+	switch format {
+	case "", payloadFormatBinary, payloadFormatString, payloadFormatJSON:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", errInvalidPayloadFormat, format)
+	}
+}
+
+// decodePayload converts a received payload to the Go value passed to the message handlers.
+// It does not use the runtime, so it can be called on any goroutine.
+func decodePayload(data []byte, format string) (any, error) {
+	switch format {
+	case payloadFormatBinary:
+		return uint8Array(data), nil
+	case payloadFormatString:
+		return string(data), nil
+	case payloadFormatJSON:
+		var value any
+
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+
+		return value, nil
+	default:
+		return arrayBuffer(data), nil
+	}
+}
+
+// jsValue is implemented by Go values with their own conversion to JavaScript.
+type jsValue interface {
+	toValue(runtime *sobek.Runtime) (sobek.Value, error)
+}
+
+// arrayBuffer is converted to an ArrayBuffer backed by the bytes.
+type arrayBuffer []byte
+
+func (b arrayBuffer) toValue(runtime *sobek.Runtime) (sobek.Value, error) {
+	return runtime.ToValue(runtime.NewArrayBuffer(b)), nil
+}
+
+// uint8Array is converted to a Uint8Array backed by the bytes.
+type uint8Array []byte
+
+func (b uint8Array) toValue(runtime *sobek.Runtime) (sobek.Value, error) {
+	return runtime.New(runtime.Get("Uint8Array"), runtime.ToValue(runtime.NewArrayBuffer(b)))
+}
+
+// toValue converts a Go value to JavaScript. It must be called on the event loop,
+// since JavaScript values must not be created on other goroutines.
+func toValue(runtime *sobek.Runtime, value any) (sobek.Value, error) {
+	if v, ok := value.(jsValue); ok {
+		return v.toValue(runtime)
+	}
+
+	return runtime.ToValue(value), nil
+}
+
+// toValues converts Go values to JavaScript like toValue.
+func toValues(runtime *sobek.Runtime, values []any) ([]sobek.Value, error) {
+	converted := make([]sobek.Value, 0, len(values))
+
+	for _, value := range values {
+		v, err := toValue(runtime, value)
+		if err != nil {
+			return nil, err
+		}
+
+		converted = append(converted, v)
+	}
+
+	return converted, nil
+}
+
+// payloadFormat returns the format of messages passed to a subscription with the given format.
+func (c *client) payloadFormat(format string) string {
+	if len(format) != 0 {
+		return format
+	}
+
+	return c.clientOpts.PayloadFormat
+}
@@ -0,0 +1,111 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/grafana/sobek"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_decodePayload(t *testing.T) {
+	t.Parallel()
+
+	value, err := decodePayload([]byte("hello"), "")
+	require.NoError(t, err)
+	require.Equal(t, arrayBuffer("hello"), value)
+
+	value, err = decodePayload([]byte("hello"), payloadFormatBinary)
+	require.NoError(t, err)
+	require.Equal(t, uint8Array("hello"), value)
+
+	value, err = decodePayload([]byte("hello"), payloadFormatString)
+	require.NoError(t, err)
+	require.Equal(t, "hello", value)
+
+	value, err = decodePayload([]byte(`{"temperature":21.5,"tags":["a"]}`), payloadFormatJSON)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"temperature": 21.5, "tags": []any{"a"}}, value)
+
+	_, err = decodePayload([]byte("{"), payloadFormatJSON)
+	require.Error(t, err)
+}
+
+func Test_toValue(t *testing.T) {
+	t.Parallel()
+
+	rt := sobek.New()
+
+	value, err := toValue(rt, arrayBuffer("hi"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("hi"), value.Export().(sobek.ArrayBuffer).Bytes()) //nolint:forcetypeassert
+
+	value, err = toValue(rt, uint8Array("hi"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("hi"), value.Export())
+
+	require.NoError(t, rt.Set("value", value))
+
+	isUint8Array, err := rt.RunString(`value instanceof Uint8Array`)
+	require.NoError(t, err)
+	require.True(t, isUint8Array.ToBoolean())
+
+	value, err = toValue(rt, "hello")
+	require.NoError(t, err)
+	require.Equal(t, "hello", value.Export())
+}
+
+func Test_validatePayloadFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []string{"", payloadFormatBinary, payloadFormatString, payloadFormatJSON} {
+		require.NoError(t, validatePayloadFormat(format))
+	}
+
+	require.ErrorIs(t, validatePayloadFormat("xml"), errInvalidPayloadFormat)
+}
+
+func Test_encodePayload(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		script   string
+		encoding string
+		want     string
+		wantErr  error
+	}{
+		{name: "string", script: `"hello"`, want: "hello"},
+		{name: "ArrayBuffer", script: `new Uint8Array([104, 105]).buffer`, want: "hi"},
+		{name: "Uint8Array", script: `new Uint8Array([0, 104, 105, 0]).subarray(1, 3)`, want: "hi"},
+		{name: "Uint16Array", script: `new Uint16Array([0x6968])`, want: "hi"},
+		{name: "DataView", script: `new DataView(new Uint8Array([0, 104, 105]).buffer, 1)`, want: "hi"},
+		{name: "object", script: `({ temperature: 21.5 })`, want: `{"temperature":21.5}`},
+		{name: "array", script: `[1, 2]`, want: `[1,2]`},
+		{name: "json string", script: `"hello"`, encoding: payloadEncodingJSON, want: `"hello"`},
+		{name: "json number", script: `42`, encoding: payloadEncodingJSON, want: `42`},
+		{name: "number", script: `42`, wantErr: errInvalidType},
+		{name: "function", script: `(() => {})`, encoding: payloadEncodingJSON, wantErr: errInvalidType},
+		{name: "unknown encoding", script: `"hello"`, encoding: "xml", wantErr: errInvalidPayloadEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rt := sobek.New()
+
+			value, err := rt.RunString(tt.script)
+			require.NoError(t, err)
+
+			data, err := encodePayload(value, tt.encoding, rt)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(data))
+		})
+	}
+}
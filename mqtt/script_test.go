@@ -8,13 +8,58 @@ import (
 	"github.com/grafana/sobek"
 	"github.com/stretchr/testify/require"
 	"go.k6.io/k6/v2/js/modulestest"
+	"go.k6.io/k6/v2/metrics"
 )
 
-func runScriptTest(t *testing.T, filename string) {
+// scriptChecks assert the metric samples emitted by the test scripts, by file name.
+var scriptChecks = map[string]func(t *testing.T, samples []metrics.Sample){ //nolint:gochecknoglobals
+	"protocol_v5_test.cjs": func(t *testing.T, samples []metrics.Sample) {
+		t.Helper()
+
+		calls := samplesOf(samples, mqttCalls)
+
+		require.NotEmpty(t, calls)
+
+		for _, sample := range calls {
+			proto, _ := sample.Tags.Get("proto")
+			require.Equal(t, "MQTT/5.0", proto)
+		}
+	},
+	"sequence_test.cjs": func(t *testing.T, samples []metrics.Sample) {
+		t.Helper()
+
+		// Only the anomalies topic reports lost, duplicated and out of order messages, one each.
+		for _, name := range []string{mqttMessagesLost, mqttMessagesDuplicated, mqttMessagesOutOfOrder} {
+			found := samplesOf(samples, name)
+
+			require.Len(t, found, 1, name)
+
+			topic, _ := found[0].Tags.Get("topic")
+			require.Equal(t, "test/sequence/anomalies", topic, name)
+			require.InDelta(t, 1, found[0].Value, 0, name)
+		}
+	},
+}
+
+// samplesOf returns the samples of the metric with the given name.
+func samplesOf(samples []metrics.Sample, name string) []metrics.Sample {
+	var found []metrics.Sample
+
+	for _, sample := range samples {
+		if sample.Metric.Name == name {
+			found = append(found, sample)
+		}
+	}
+
+	return found
+}
+
+// runScriptTest runs the test script, and returns the metric samples it emitted.
+func runScriptTest(t *testing.T, filename string) []metrics.Sample {
 	t.Helper()
 
 	runtime := newTestRuntime(t)
-	state := newTestVUState(t)
+	state, samples := newTestVUStateWithSamples(t)
 
 	module := runtime.VU.Runtime().NewObject()
 	exports := runtime.VU.Runtime().NewObject()
@@ -56,6 +101,8 @@ func runScriptTest(t *testing.T, filename string) {
 		require.NoError(t, err)
 		runtime.EventLoop.WaitOnRegistered()
 	}
+
+	return collectSamples(samples, nil)
 }
 
 func Test_script(t *testing.T) { //nolint:tparallel
@@ -68,7 +115,11 @@ func Test_script(t *testing.T) { //nolint:tparallel
 
 	for _, file := range files { //nolint:paralleltest
 		t.Run(filepath.ToSlash(file), func(t *testing.T) {
-			runScriptTest(t, file)
+			samples := runScriptTest(t, file)
+
+			if check, ok := scriptChecks[filepath.Base(file)]; ok {
+				check(t, samples)
+			}
 		})
 	}
 }
@@ -0,0 +1,163 @@
+package mqtt
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.k6.io/k6/v2/metrics"
+)
+
+// sequenceWindow is the number of sequence numbers a missing message may lag behind
+// the highest received one before it is counted as lost.
+const sequenceWindow = 1024
+
+// nextSequence returns the next sequence number of messages published by the client to the topic.
+func (c *client) nextSequence(topic string) uint64 {
+	v, _ := c.publishSequences.LoadOrStore(topic, new(atomic.Uint64))
+
+	counter, _ := v.(*atomic.Uint64)
+
+	return counter.Add(1)
+}
+
+// sequenceCounts holds the number of lost, duplicated and out of order messages detected.
+type sequenceCounts struct {
+	lost       int
+	duplicated int
+	outOfOrder int
+}
+
+// sequenceStream tracks the sequence numbers received from one publisher on one topic.
+type sequenceStream struct {
+	highest uint64
+	missing map[uint64]struct{}
+	tags    *metrics.TagSet
+}
+
+func (s *sequenceStream) observe(seq uint64) sequenceCounts {
+	var counts sequenceCounts
+
+	switch {
+	case s.highest == 0 || seq == s.highest+1:
+		s.highest = seq
+	case seq > s.highest:
+		first := s.highest + 1
+
+		if gap := seq - first; gap >= sequenceWindow {
+			counts.lost += int(gap - sequenceWindow + 1) //nolint:gosec
+			first = seq - sequenceWindow + 1
+		}
+
+		for missing := first; missing < seq; missing++ {
+			s.missing[missing] = struct{}{}
+		}
+
+		s.highest = seq
+	default:
+		if _, ok := s.missing[seq]; ok {
+			delete(s.missing, seq)
+
+			counts.outOfOrder++
+		} else if s.highest-seq >= sequenceWindow {
+			// Arrived after it was counted as lost.
+			counts.outOfOrder++
+		} else {
+			counts.duplicated++
+		}
+
+		return counts
+	}
+
+	for missing := range s.missing {
+		if s.highest-missing >= sequenceWindow {
+			delete(s.missing, missing)
+
+			counts.lost++
+		}
+	}
+
+	return counts
+}
+
+// sequenceTracker detects lost, duplicated and out of order messages
+// using the sequence numbers stamped by the publishers.
+type sequenceTracker struct {
+	streams map[string]*sequenceStream
+	mu      sync.Mutex
+}
+
+func newSequenceTracker() *sequenceTracker {
+	return &sequenceTracker{streams: make(map[string]*sequenceStream)}
+}
+
+func (t *sequenceTracker) observe(source, topic string, seq uint64, tags *metrics.TagSet) sequenceCounts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := source + "\x00" + topic
+
+	stream, ok := t.streams[key]
+	if !ok {
+		stream = &sequenceStream{missing: make(map[uint64]struct{})}
+		t.streams[key] = stream
+	}
+
+	stream.tags = tags
+
+	return stream.observe(seq)
+}
+
+// flush counts all messages still missing as lost and resets the tracker.
+func (t *sequenceTracker) flush(fn func(tags *metrics.TagSet, lost int)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, stream := range t.streams {
+		if len(stream.missing) != 0 {
+			fn(stream.tags, len(stream.missing))
+		}
+	}
+
+	clear(t.streams)
+}
+
+func (c *client) sequenceSamples(counts sequenceCounts, tags *metrics.TagSet, now time.Time) metrics.Samples {
+	var samples metrics.Samples
+
+	add := func(metric *metrics.Metric, value int) {
+		if value == 0 {
+			return
+		}
+
+		samples = append(samples, metrics.Sample{
+			TimeSeries: metrics.TimeSeries{
+				Metric: metric,
+				Tags:   tags,
+			},
+			Time:  now,
+			Value: float64(value),
+		})
+	}
+
+	add(c.metrics.mqttMessagesLost, counts.lost)
+	add(c.metrics.mqttMessagesDuplicated, counts.duplicated)
+	add(c.metrics.mqttMessagesOutOfOrder, counts.outOfOrder)
+
+	return samples
+}
+
+// flushSequences counts the messages still missing when the client ends as lost.
+func (c *client) flushSequences() {
+	var samples metrics.Samples
+
+	now := time.Now()
+
+	c.sequences.flush(func(tags *metrics.TagSet, lost int) {
+		samples = append(samples, c.sequenceSamples(sequenceCounts{lost: lost}, tags, now)...)
+	})
+
+	if len(samples) != 0 {
+		metrics.PushIfNotDone(c.vu.Context(), c.vu.State().Samples, samples)
+	}
+}
@@ -0,0 +1,66 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/v2/metrics"
+)
+
+func Test_sequenceStream_observe(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		seqs []uint64
+		want sequenceCounts
+	}{
+		{name: "in order", seqs: []uint64{1, 2, 3, 4}},
+		{name: "late start", seqs: []uint64{42, 43, 44}},
+		{name: "duplicate", seqs: []uint64{1, 2, 2, 3, 1}, want: sequenceCounts{duplicated: 2}},
+		{name: "out of order", seqs: []uint64{1, 3, 2, 4}, want: sequenceCounts{outOfOrder: 1}},
+		{name: "out of order duplicate", seqs: []uint64{1, 3, 2, 2}, want: sequenceCounts{outOfOrder: 1, duplicated: 1}},
+		{name: "gap within window", seqs: []uint64{1, 5, 6}},
+		{name: "gap beyond window", seqs: []uint64{1, sequenceWindow + 11}, want: sequenceCounts{lost: 10}},
+		{name: "gap leaves window", seqs: []uint64{1, 3, 3 + sequenceWindow}, want: sequenceCounts{lost: 1}},
+		{name: "late after lost", seqs: []uint64{1, 3, 3 + sequenceWindow, 2}, want: sequenceCounts{lost: 1, outOfOrder: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			stream := &sequenceStream{missing: make(map[uint64]struct{})}
+
+			var got sequenceCounts
+
+			for _, seq := range tt.seqs {
+				counts := stream.observe(seq)
+
+				got.lost += counts.lost
+				got.duplicated += counts.duplicated
+				got.outOfOrder += counts.outOfOrder
+			}
+
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_sequenceTracker_flush(t *testing.T) {
+	t.Parallel()
+
+	tracker := newSequenceTracker()
+
+	tracker.observe("a", "test/seq", 1, nil)
+	tracker.observe("a", "test/seq", 4, nil)
+	tracker.observe("b", "test/seq", 1, nil)
+	tracker.observe("b", "test/seq", 2, nil)
+
+	lost := 0
+
+	tracker.flush(func(_ *metrics.TagSet, n int) { lost += n })
+
+	require.Equal(t, 2, lost)
+	require.Empty(t, tracker.streams)
+}
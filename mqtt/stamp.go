@@ -0,0 +1,210 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"time"
+
+	paho5 "github.com/eclipse/paho.golang/paho"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Stamps carry measurement data from the publisher to the subscribers of a message.
+// With MQTT v5 they are sent as user properties, otherwise as a binary header prepended to the payload:
+//
+//	magic (4 bytes) | flags (1 byte) | sent at (8 bytes, if flagSentAt) |
+//	source length (1 byte) | source | sequence (8 bytes, if flagSequence)
+const (
+	stampMagic            = "\x00k6\x01"
+	stampPropertySentAt   = "k6-sent-at"
+	stampPropertySource   = "k6-source"
+	stampPropertySequence = "k6-seq"
+)
+
+const (
+	flagSentAt byte = 1 << iota
+	flagSequence
+)
+
+type stamp struct {
+	sentAt time.Time
+	source string
+	seq    uint64
+}
+
+func (s *stamp) flags() byte {
+	var flags byte
+
+	if !s.sentAt.IsZero() {
+		flags |= flagSentAt
+	}
+
+	if s.seq != 0 {
+		flags |= flagSequence
+	}
+
+	return flags
+}
+
+// wrap prepends the stamp header to the payload.
+func (s *stamp) wrap(payload []byte) []byte {
+	flags := s.flags()
+
+	buf := make([]byte, 0, len(stampMagic)+1+8+1+len(s.source)+8+len(payload)) //nolint:mnd
+
+	buf = append(buf, stampMagic...)
+	buf = append(buf, flags)
+
+	if flags&flagSentAt != 0 {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(s.sentAt.UnixNano())) //nolint:gosec
+	}
+
+	if flags&flagSequence != 0 {
+		buf = append(buf, byte(len(s.source)))
+		buf = append(buf, s.source...)
+		buf = binary.BigEndian.AppendUint64(buf, s.seq)
+	}
+
+	return append(buf, payload...)
+}
+
+// unwrapStamp strips the stamp header from the payload.
+// It returns ok == false and the unchanged payload if there is no valid header.
+func unwrapStamp(payload []byte) (stamp, []byte, bool) {
+	var s stamp
+
+	rest, found := bytes.CutPrefix(payload, []byte(stampMagic))
+	if !found || len(rest) < 1 {
+		return s, payload, false
+	}
+
+	flags, rest := rest[0], rest[1:]
+
+	if flags&flagSentAt != 0 {
+		if len(rest) < 8 { //nolint:mnd
+			return s, payload, false
+		}
+
+		s.sentAt = time.Unix(0, int64(binary.BigEndian.Uint64(rest))) //nolint:gosec
+		rest = rest[8:]
+	}
+
+	if flags&flagSequence != 0 {
+		if len(rest) < 1 || len(rest) < 1+int(rest[0])+8 {
+			return s, payload, false
+		}
+
+		n := int(rest[0])
+
+		s.source = string(rest[1 : 1+n])
+		s.seq = binary.BigEndian.Uint64(rest[1+n:])
+		rest = rest[1+n+8:]
+	}
+
+	return s, rest, true
+}
+
+// addTo adds the stamp to the user properties.
+func (s *stamp) addTo(props *paho5.PublishProperties) {
+	if !s.sentAt.IsZero() {
+		props.User.Add(stampPropertySentAt, strconv.FormatInt(s.sentAt.UnixNano(), 10))
+	}
+
+	if s.seq != 0 {
+		props.User.Add(stampPropertySource, s.source)
+		props.User.Add(stampPropertySequence, strconv.FormatUint(s.seq, 10))
+	}
+}
+
+// stampFromProperties reads the stamp from the user properties.
+func stampFromProperties(props *paho5.PublishProperties) (stamp, bool) {
+	var s stamp
+
+	if props == nil {
+		return s, false
+	}
+
+	found := false
+
+	if sentAt := props.User.Get(stampPropertySentAt); sentAt != "" {
+		nanos, err := strconv.ParseInt(sentAt, 10, 64)
+		if err != nil {
+			return s, false
+		}
+
+		s.sentAt, found = time.Unix(0, nanos), true
+	}
+
+	if seq := props.User.Get(stampPropertySequence); seq != "" {
+		n, err := strconv.ParseUint(seq, 10, 64)
+		if err != nil {
+			return s, false
+		}
+
+		s.source, s.seq, found = props.User.Get(stampPropertySource), n, true
+	}
+
+	return s, found
+}
+
+func (c *client) stamping() bool {
+	return c.clientOpts != nil && (c.clientOpts.MeasureLatency || c.clientOpts.TrackSequence)
+}
+
+// stampOutgoing stamps an outgoing message when latency measurement or sequence tracking is enabled.
+func (c *client) stampOutgoing(
+	topic string, payload []byte, props *paho5.PublishProperties, now time.Time,
+) ([]byte, *paho5.PublishProperties) {
+	if !c.stamping() {
+		return payload, props
+	}
+
+	var s stamp
+
+	if c.clientOpts.MeasureLatency {
+		s.sentAt = now
+	}
+
+	if c.clientOpts.TrackSequence {
+		s.source, s.seq = c.instanceID, c.nextSequence(topic)
+	}
+
+	if _, ok := c.pahoClient.(propertiesPublisher); ok {
+		if props == nil {
+			props = new(paho5.PublishProperties)
+		}
+
+		s.addTo(props)
+
+		return payload, props
+	}
+
+	return s.wrap(payload), props
+}
+
+// unstampIncoming reads the stamp of an incoming message and returns the payload without the stamp header.
+// The header is stripped whatever the options of the client, since it may have been added by another client,
+// and it is looked for in MQTT v5 messages too, which may come from an MQTT 3.1.1 publisher.
+// The parts of the stamp are only returned if the client measures latency or tracks sequences.
+func (c *client) unstampIncoming(msg paho.Message) (stamp, []byte, bool) {
+	s, payload, ok := unwrapStamp(msg.Payload())
+
+	if mp, isV5 := msg.(messageWithProperties); isV5 && !ok {
+		s, ok = stampFromProperties(mp.Properties())
+	}
+
+	if !ok || !c.stamping() {
+		return stamp{}, payload, false
+	}
+
+	if !c.clientOpts.MeasureLatency {
+		s.sentAt = time.Time{}
+	}
+
+	if !c.clientOpts.TrackSequence {
+		s.source, s.seq = "", 0
+	}
+
+	return s, payload, true
+}
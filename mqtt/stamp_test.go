@@ -0,0 +1,172 @@
+package mqtt
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	paho5 "github.com/eclipse/paho.golang/paho"
+	"github.com/grafana/sobek"
+	"github.com/grafana/xk6-mqtt/internal/broker"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_stamp_wrap(t *testing.T) {
+	t.Parallel()
+
+	sentAt := time.Unix(0, time.Now().UnixNano())
+	s := stamp{sentAt: sentAt}
+
+	got, payload, ok := unwrapStamp(s.wrap([]byte("Hello, MQTT!")))
+
+	require.True(t, ok)
+	require.True(t, sentAt.Equal(got.sentAt))
+	require.Equal(t, "Hello, MQTT!", string(payload))
+
+	got, payload, ok = unwrapStamp(s.wrap(nil))
+
+	require.True(t, ok)
+	require.True(t, sentAt.Equal(got.sentAt))
+	require.Empty(t, payload)
+}
+
+func Test_stamp_wrap_sequence(t *testing.T) {
+	t.Parallel()
+
+	s := stamp{sentAt: time.Unix(0, 42), source: "0123456789abcdef", seq: 7}
+
+	got, payload, ok := unwrapStamp(s.wrap([]byte("Hello, MQTT!")))
+
+	require.True(t, ok)
+	require.Equal(t, s, got)
+	require.Equal(t, "Hello, MQTT!", string(payload))
+
+	props := new(paho5.PublishProperties)
+
+	s.addTo(props)
+
+	got, ok = stampFromProperties(props)
+
+	require.True(t, ok)
+	require.Equal(t, s.source, got.source)
+	require.Equal(t, s.seq, got.seq)
+}
+
+func Test_unwrapStamp_invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]byte{
+		"plain":     []byte("Hello, MQTT!"),
+		"empty":     nil,
+		"no flags":  []byte(stampMagic),
+		"truncated": append([]byte(stampMagic), flagSentAt, 0, 1),
+		"no source": append([]byte(stampMagic), flagSequence, 4, 'a'),
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, payload, ok := unwrapStamp(input)
+
+			require.False(t, ok)
+			require.Equal(t, input, payload)
+		})
+	}
+}
+
+func Test_stampFromProperties(t *testing.T) {
+	t.Parallel()
+
+	sentAt := time.Unix(0, time.Now().UnixNano())
+	props := new(paho5.PublishProperties)
+
+	props.User.Add("tenant", "acme")
+
+	s := stamp{sentAt: sentAt}
+	s.addTo(props)
+
+	got, ok := stampFromProperties(props)
+
+	require.True(t, ok)
+	require.True(t, sentAt.Equal(got.sentAt))
+
+	_, ok = stampFromProperties(&paho5.PublishProperties{})
+	require.False(t, ok)
+
+	_, ok = stampFromProperties(nil)
+	require.False(t, ok)
+}
+
+func TestClientMessageLatency(t *testing.T) {
+	t.Parallel()
+
+	runtime := newTestRuntime(t)
+	mm := newMqttMetrics(runtime.VU)
+	logger := runtime.VU.InitEnv().Logger
+	state, samples := newTestVUStateWithSamples(t)
+
+	runtime.MoveToVUContext(state)
+
+	rt := runtime.VU.Runtime()
+	address := rt.ToValue(os.Getenv(broker.EnvBrokerAddress)) //nolint:forbidigo // test reads the embedded broker address from env
+	topic := "test/latency/mixed"
+
+	// The MQTT 3.1.1 publisher stamps the payload, which the MQTT v5 subscriber must unwrap.
+	publisher := newTestClient(t, logger, runtime.VU, mm)
+	publisher.clientOpts.MeasureLatency = true
+
+	measuring := newTestClient(t, logger, runtime.VU, mm)
+	measuring.clientOpts.ProtocolVersion = protocolVersion5
+	measuring.clientOpts.MeasureLatency = true
+
+	// Subscribers without the option still receive the payload without the stamp.
+	plain := newTestClient(t, logger, runtime.VU, mm)
+
+	received := make(map[*client]string)
+
+	for _, subscriber := range []*client{measuring, plain} {
+		onEvent(t, subscriber, "message", func(_ sobek.Value, args ...sobek.Value) (sobek.Value, error) {
+			received[subscriber] = string(args[1].Export().(sobek.ArrayBuffer).Bytes()) //nolint:forcetypeassert
+
+			if len(received) == 2 {
+				require.NoError(t, measuring.end(nil))
+				require.NoError(t, plain.end(nil))
+				require.NoError(t, publisher.end(nil))
+			}
+
+			return sobek.Undefined(), nil
+		})
+	}
+
+	err := runtime.EventLoop.Start(func() error {
+		for _, subscriber := range []*client{measuring, plain} {
+			_, err := subscriber.connect(address, nil)
+			require.NoError(t, err)
+			require.NoError(t, subscriber.subscribe(rt.ToValue(topic), nil))
+		}
+
+		_, err := publisher.connect(address, nil)
+		require.NoError(t, err)
+		require.NoError(t, publisher.publish(topic, rt.ToValue("Hello, MQTT!"), nil))
+
+		return nil
+	})
+
+	require.NoError(t, err)
+
+	runtime.EventLoop.WaitOnRegistered()
+
+	require.Equal(t, "Hello, MQTT!", received[measuring])
+	require.Equal(t, "Hello, MQTT!", received[plain])
+
+	latencies := collectSamples(samples, mm.mqttMessageLatency)
+
+	require.Len(t, latencies, 1)
+
+	proto, _ := latencies[0].Tags.Get("proto")
+	require.Equal(t, "MQTT/5.0", proto)
+
+	sampleTopic, _ := latencies[0].Tags.Get("topic")
+	require.Equal(t, topic, sampleTopic)
+}
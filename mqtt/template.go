@@ -0,0 +1,131 @@
+package mqtt
+
+import (
+	"crypto/rand"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"go.k6.io/k6/v2/lib"
+)
+
+const (
+	defaultRandLength = 8
+	randAlphabet      = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+var templatePlaceholder = regexp.MustCompile(`\{(\w+)(?::(\d+))?\}`) //nolint:gochecknoglobals
+
+// templateNames are the names of the placeholders filled in by templateVars, when their value is available.
+var templateNames = map[string]struct{}{"scenario": {}, "vu": {}, "iter": {}} //nolint:gochecknoglobals
+
+// expandTemplate replaces the {name} placeholders of tmpl with the values of vars,
+// and {rand:N} placeholders with N random lowercase letters and digits.
+// The known placeholders without a value, like {scenario} outside of a scenario, are replaced with an empty string.
+// Other placeholders are kept as they are, since they may be a literal part of the client ID.
+func expandTemplate(tmpl string, vars map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(tmpl, func(placeholder string) string {
+		match := templatePlaceholder.FindStringSubmatch(placeholder)
+		name, arg := match[1], match[2]
+
+		if name == "rand" {
+			length := defaultRandLength
+
+			if n, err := strconv.Atoi(arg); err == nil && n > 0 {
+				length = n
+			}
+
+			return randomString(length)
+		}
+
+		if value, ok := vars[name]; ok {
+			return value
+		}
+
+		if _, ok := templateNames[name]; ok {
+			return ""
+		}
+
+		return placeholder
+	})
+}
+
+// replacePlaceholders replaces the {name} placeholders of tmpl with the values of vars, keeping the others.
+// The client ID is expanded by expandTemplate when connecting, while the username and password are not.
+func replacePlaceholders(tmpl string, vars map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(tmpl, func(placeholder string) string {
+		if value, ok := vars[templatePlaceholder.FindStringSubmatch(placeholder)[1]]; ok {
+			return value
+		}
+
+		return placeholder
+	})
+}
+
+func randomString(length int) string {
+	buf := make([]byte, length)
+
+	_, _ = rand.Read(buf)
+
+	for i, b := range buf {
+		buf[i] = randAlphabet[int(b)%len(randAlphabet)]
+	}
+
+	return string(buf)
+}
+
+// templateVars returns the values of the {scenario}, {vu} and {iter} template placeholders.
+func (c *client) templateVars() map[string]string {
+	vars := make(map[string]string)
+
+	if state := c.vu.State(); state != nil {
+		vars["vu"] = strconv.FormatUint(state.VUID, 10)
+		vars["iter"] = strconv.FormatInt(state.Iteration, 10)
+	}
+
+	if scenario := lib.GetScenarioState(c.vu.Context()); scenario != nil {
+		vars["scenario"] = scenario.Name
+	}
+
+	return vars
+}
+
+// clientIDRegistry tracks the client IDs of the connected clients of all VUs,
+// so that reusing an ID, which makes the broker disconnect the other client, can be reported.
+type clientIDRegistry struct {
+	live map[string]int
+	mu   sync.Mutex
+}
+
+func newClientIDRegistry() *clientIDRegistry {
+	return &clientIDRegistry{live: make(map[string]int)}
+}
+
+// acquire registers a connected client with the given ID. It returns false if the ID is already in use.
+func (r *clientIDRegistry) acquire(id string) bool {
+	if r == nil || len(id) == 0 {
+		return true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.live[id]++
+
+	return r.live[id] == 1
+}
+
+func (r *clientIDRegistry) release(id string) {
+	if r == nil || len(id) == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.live[id] <= 1 {
+		delete(r.live, id)
+	} else {
+		r.live[id]--
+	}
+}
@@ -0,0 +1,91 @@
+package mqtt
+
+import (
+	"os"
+	"testing"
+
+	"github.com/grafana/xk6-mqtt/internal/broker"
+	"github.com/stretchr/testify/require"
+	"go.k6.io/k6/v2/lib"
+)
+
+func Test_expandTemplate(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]string{"index": "7"}
+
+	require.Equal(t, "device-7", expandTemplate("device-{index}", vars))
+	require.Equal(t, "device-7-7", expandTemplate("device-{index}-{index}", vars))
+	require.Equal(t, "device{42}-{site}", expandTemplate("device{42}-{site}", vars))
+	require.Equal(t, "device--7", expandTemplate("device-{scenario}-{index}", vars))
+	require.Equal(t, "device", expandTemplate("device", vars))
+}
+
+func Test_replacePlaceholders(t *testing.T) {
+	t.Parallel()
+
+	vars := map[string]string{"index": "7"}
+
+	require.Equal(t, "device-7-{vu}-{rand:6}", replacePlaceholders("device-{index}-{vu}-{rand:6}", vars))
+	require.Equal(t, "device", replacePlaceholders("device", vars))
+}
+
+func Test_expandTemplate_rand(t *testing.T) {
+	t.Parallel()
+
+	require.Regexp(t, `^dev-[a-z0-9]{6}$`, expandTemplate("dev-{rand:6}", nil))
+	require.Regexp(t, `^dev-[a-z0-9]{8}$`, expandTemplate("dev-{rand}", nil))
+	require.NotEqual(t, expandTemplate("{rand:16}", nil), expandTemplate("{rand:16}", nil))
+}
+
+func Test_clientIDRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry := newClientIDRegistry()
+
+	require.True(t, registry.acquire("device-1"))
+	require.False(t, registry.acquire("device-1"))
+	require.True(t, registry.acquire(""))
+
+	registry.release("device-1")
+	require.False(t, registry.acquire("device-1"))
+
+	registry.release("device-1")
+	registry.release("device-1")
+	require.True(t, registry.acquire("device-1"))
+}
+
+func TestClientIDTemplate(t *testing.T) {
+	t.Parallel()
+
+	runtime := newTestRuntime(t)
+	mm := newMqttMetrics(runtime.VU)
+	logger := runtime.VU.InitEnv().Logger
+	state := newTestVUState(t)
+	state.VUID = 3
+	state.Iteration = 5
+
+	runtime.MoveToVUContext(state)
+	runtime.VU.CtxField = lib.WithScenarioState(runtime.VU.CtxField, &lib.ScenarioState{Name: "default"})
+
+	client := newTestClient(t, logger, runtime.VU, mm)
+	client.clientIDs = newClientIDRegistry()
+
+	toValue := runtime.VU.Runtime().ToValue
+
+	client.clientOpts.ClientId = toValue("dev-{scenario}-{vu}-{iter}-{rand:6}")
+
+	err := runtime.EventLoop.Start(func() error {
+		_, err := client.connect(toValue(os.Getenv(broker.EnvBrokerAddress)), nil) //nolint:forbidigo // test reads the embedded broker address from env
+		require.NoError(t, err)
+		require.Regexp(t, `^dev-default-3-5-[a-z0-9]{6}$`, client.clientID)
+		require.NoError(t, client.end(nil))
+		require.Empty(t, client.clientID)
+
+		return nil
+	})
+
+	require.NoError(t, err)
+
+	runtime.EventLoop.WaitOnRegistered()
+}
@@ -0,0 +1,45 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const testTopic = "test/pool"
+const size = 3
+const received = new Set()
+var connected = 0
+var connectEvents = 0
+
+module.exports = async () => {
+  const pool = new mqtt.ClientPool({ size, client_id: `k6-pool-{index}-${Date.now()}`, payload_format: "string" })
+
+  assert.equal(size, pool.size, "Unexpected pool size")
+  assert.equal(size, pool.clients.length, "Unexpected number of clients")
+
+  const listener = pool.clients[0]
+
+  listener.on("message", (topic, message) => {
+    received.add(message)
+
+    if (received.size === size) pool.end()
+  })
+
+  // The clients connect concurrently, while their events are passed to the shared event loop.
+  for (const client of pool.clients) {
+    client.on("connect", () => connectEvents++)
+  }
+
+  await pool.connectAsync(__ENV.MQTT_BROKER_ADDRESS)
+
+  connected = pool.clients.filter((client) => client.connected).length
+
+  await listener.subscribeAsync(`${testTopic}/+`)
+
+  for (const [index, client] of pool.clients.entries()) {
+    await client.publishAsync(`${testTopic}/${index}`, `device ${index}`)
+  }
+}
+
+module.exports.teardown = () => {
+  assert.equal(size, connected, "Not all pool clients connected")
+  assert.equal(size, connectEvents, "Not all pool clients fired the connect event")
+  assert.equal(size, received.size, "Unexpected number of messages")
+  assert.true(received.has("device 2"), "Message of the last client not received")
+}
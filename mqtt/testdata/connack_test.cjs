@@ -0,0 +1,48 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+var connectHandlerCalled = false
+
+function resumeSession(version) {
+  const client = new mqtt.Client({ client_id: "connack-v" + version, protocol_version: version })
+
+  var connack = client.connect(__ENV.MQTT_BROKER_ADDRESS, { clean_session: true })
+
+  assert.false(connack.session_present, "Clean session should not be present")
+  assert.equal(0, connack.return_code, "Unexpected return code")
+
+  client.end()
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS, { clean_session: false })
+  client.end()
+
+  connack = client.connect(__ENV.MQTT_BROKER_ADDRESS, { clean_session: false })
+
+  assert.true(connack.session_present, "Persistent session should be present")
+
+  client.end()
+}
+
+module.exports = async () => {
+  resumeSession(4)
+  resumeSession(5)
+
+  const client = new mqtt.Client({ client_id: "connack-v5", protocol_version: 5 })
+
+  client.on("connect", async (connack) => {
+    assert.true(connack.session_present, "Connect handler should receive the connack")
+
+    connectHandlerCalled = true
+
+    await client.endAsync()
+  })
+
+  const connack = await client.connectAsync(__ENV.MQTT_BROKER_ADDRESS, { clean_session: false })
+
+  assert.true(connack.session_present, "Persistent session should be present")
+  assert.equal(0, connack.return_code, "Unexpected return code")
+}
+
+module.exports.teardown = () => {
+  assert.true(connectHandlerCalled, "Connect handler was not called")
+}
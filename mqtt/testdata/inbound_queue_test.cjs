@@ -0,0 +1,34 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const testTopic = "test/inbound"
+const count = 5
+const received = []
+
+module.exports = () => {
+  const client = new mqtt.Client({ inbound_queue: { size: 2, overflow: "block" } })
+
+  client.on("message", (topic, payload) => {
+    if (topic !== testTopic) return
+
+    received.push(String.fromCharCode(...new Uint8Array(payload)))
+
+    if (received.length === count) client.end()
+  })
+
+  client.on("connect", async () => {
+    await client.subscribeAsync(testTopic)
+
+    for (let i = 0; i < count; i++) {
+      await client.publishAsync(testTopic, `message ${i}`)
+    }
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+}
+
+module.exports.teardown = () => {
+  assert.equal(count, received.length, "Unexpected number of messages")
+  assert.equal("message 0", received[0], "Messages out of order")
+  assert.equal(`message ${count - 1}`, received[count - 1], "Messages out of order")
+}
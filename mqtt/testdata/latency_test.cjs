@@ -0,0 +1,35 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const testTopic = "test/latency"
+var received = {}
+
+function measure(version) {
+  const topic = `${testTopic}/${version}`
+  const client = new mqtt.Client({ protocol_version: version, measure_latency: true })
+
+  client.on("connect", async () => {
+    await client.subscribeAsync(topic)
+    await client.publishAsync(topic, "Hello, MQTT!")
+  })
+
+  client.on("message", (t, message) => {
+    assert.equal(topic, t, "Unexpected topic")
+    assert.equal("Hello, MQTT!", String.fromCharCode.apply(null, new Uint8Array(message)), "Stamp not removed from payload")
+
+    received[version] = true
+    client.end()
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+}
+
+module.exports = () => {
+  measure(4)
+  measure(5)
+}
+
+module.exports.teardown = () => {
+  assert.true(received[4], "MQTT 3.1.1 message was not received")
+  assert.true(received[5], "MQTT v5 message was not received")
+}
@@ -0,0 +1,57 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const testTopic = "test/listeners"
+const counts = { connect: 0, on: 0, once: 0, off: 0 }
+var done = false
+
+module.exports = () => {
+  const client = new mqtt.Client()
+
+  client.on("connect", async () => {
+    counts.connect++
+
+    await client.subscribeAsync(testTopic)
+    await client.publishAsync(testTopic, "first")
+  })
+
+  client.on("connect", () => {
+    counts.connect++
+  })
+
+  const removed = () => {
+    counts.off++
+  }
+
+  client.on("message", removed)
+
+  client.once("message", () => {
+    counts.once++
+  })
+
+  client.on("message", () => {
+    counts.on++
+
+    if (counts.on === 1) {
+      client.off("message", removed)
+      client.publish(testTopic, "second")
+
+      return
+    }
+
+    done = true
+
+    client.removeAllListeners()
+    client.end()
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+}
+
+module.exports.teardown = () => {
+  assert.true(done, "Second message was not received")
+  assert.equal(2, counts.connect, "Both connect listeners should be called")
+  assert.equal(2, counts.on, "Listener should receive both messages")
+  assert.equal(1, counts.once, "Once listener should be called only once")
+  assert.equal(1, counts.off, "Removed listener should not be called again")
+}
@@ -0,0 +1,47 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+var checked = {}
+
+function check(version) {
+  const topic = `test/packet/${version}`
+  const client = new mqtt.Client({ protocol_version: version })
+
+  client.on("connect", async () => {
+    await client.publishAsync(topic, "retained", { qos: 1, retain: true })
+    await client.subscribeAsync(topic, { qos: 0 })
+  })
+
+  client.on("message", async (t, message, packet) => {
+    if (checked[version]) {
+      return
+    }
+
+    const start = Date.now()
+
+    assert.equal(topic, t, "Unexpected topic")
+    assert.true(packet.retain, "Retained message not flagged")
+    assert.equal(0, packet.qos, "QoS not downgraded to subscription QoS")
+    assert.false(packet.dup, "Unexpected duplicate flag")
+    assert.equal(0, packet.message_id, "Unexpected message ID for QoS 0 message")
+    assert.true(packet.received_at > start - 60000 && packet.received_at <= start, "Unexpected receive timestamp")
+
+    checked[version] = true
+
+    // Clear the retained message.
+    await client.publishAsync(topic, "", { qos: 1, retain: true })
+    client.end()
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+}
+
+module.exports = () => {
+  check(4)
+  check(5)
+}
+
+module.exports.teardown = () => {
+  assert.true(checked[4], "MQTT 3.1.1 message packet was not checked")
+  assert.true(checked[5], "MQTT v5 message packet was not checked")
+}
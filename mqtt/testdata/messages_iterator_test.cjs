@@ -0,0 +1,70 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const testTopic = "test/iterator"
+const received = []
+var ended = false
+var overflowed = false
+var iterated = false
+
+module.exports = () => {
+  const client = new mqtt.Client()
+
+  client.on("connect", async () => {
+    await client.subscribeAsync(`${testTopic}/#`)
+
+    const messages = client.messages(`${testTopic}/sensors/+`)
+    const small = client.messages(`${testTopic}/sensors/+`, { buffer: 1, overflow: "error" })
+
+    for (const sensor of ["a", "b", "c"]) {
+      await client.publishAsync(`${testTopic}/sensors/${sensor}`, `value ${sensor}`)
+      await client.publishAsync(`${testTopic}/other`, "ignored")
+    }
+
+    while (received.length < 3) {
+      const { value, done } = await messages.next()
+
+      assert.false(done, "Iterator ended early")
+
+      received.push(value.topic)
+    }
+
+    try {
+      for (let result = await small.next(); !result.done; result = await small.next()) {}
+    } catch (e) {
+      assert.equal("messages", e.method, "Unexpected error method")
+
+      overflowed = true
+    }
+
+    // for await is not supported by the runtime, so the async iteration protocol is followed by hand.
+    const iterable = client.messages(`${testTopic}/iterable`)
+    const iterator = iterable[Symbol.for("Symbol.asyncIterator")]()
+
+    await client.publishAsync(`${testTopic}/iterable`, "iterated")
+
+    const result = await iterator.next()
+
+    assert.equal(`${testTopic}/iterable`, result.value.topic, "Unexpected topic")
+    assert.true((await iterator.return()).done, "Iterator should end when returned")
+
+    iterated = true
+
+    const pending = messages.next()
+
+    client.end()
+
+    ended = (await pending).done
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+}
+
+module.exports.teardown = () => {
+  assert.equal(3, received.length, "Unexpected number of messages")
+  assert.equal(`${testTopic}/sensors/a`, received[0], "Messages out of order")
+  assert.equal(`${testTopic}/sensors/c`, received[2], "Messages out of order")
+  assert.true(overflowed, "Iterator with overflow error policy should reject")
+  assert.true(ended, "Iterator should end when the client ends")
+  assert.true(iterated, "Iterator should be obtained through Symbol.asyncIterator")
+}
@@ -0,0 +1,69 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const testTopic = "test/format"
+var received = {}
+var failed = false
+
+module.exports = () => {
+  const client = new mqtt.Client({ payload_format: "string" })
+
+  const finish = () => {
+    if (received.string && received.json && received.binary && received.waited && received.iterated && failed) {
+      client.end()
+    }
+  }
+
+  client.on("message", (topic, message) => {
+    if (topic === `${testTopic}/string`) {
+      received.string = message
+    } else if (topic === `${testTopic}/json`) {
+      received.json = message
+    } else if (topic === `${testTopic}/binary`) {
+      received.binary = message
+    }
+
+    finish()
+  })
+
+  client.on("error", (err) => {
+    assert.equal("message", err.method, "Unexpected error method")
+
+    failed = true
+    finish()
+  })
+
+  client.on("connect", async () => {
+    await client.subscribeAsync(`${testTopic}/string`)
+    await client.subscribeAsync(`${testTopic}/json`, { payload_format: "json" })
+    await client.subscribeAsync(`${testTopic}/invalid`, { payload_format: "json" })
+    await client.subscribeAsync(`${testTopic}/binary`, { payload_format: "binary" })
+    await client.subscribeAsync(`${testTopic}/waited`)
+
+    const waiting = client.waitForMessage(`${testTopic}/waited`, { payload_format: "json" })
+    const messages = client.messages(`${testTopic}/waited`)
+
+    await client.publishAsync(`${testTopic}/string`, "Hello, string!")
+    await client.publishAsync(`${testTopic}/json`, JSON.stringify({ temperature: 21.5 }))
+    await client.publishAsync(`${testTopic}/invalid`, "not json")
+    await client.publishAsync(`${testTopic}/binary`, new Uint8Array([1, 2, 3]))
+    await client.publishAsync(`${testTopic}/waited`, JSON.stringify({ humidity: 40 }))
+
+    received.waited = (await waiting).payload
+    received.iterated = (await messages.next()).value.payload
+
+    finish()
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+}
+
+module.exports.teardown = () => {
+  assert.equal("Hello, string!", received.string, "Unexpected string payload")
+  assert.equal(21.5, received.json.temperature, "Unexpected JSON payload")
+  assert.true(received.binary instanceof Uint8Array, "Binary payload should be a Uint8Array")
+  assert.equal(3, received.binary[2], "Unexpected binary payload")
+  assert.equal(40, received.waited.humidity, "waitForMessage should apply its payload format")
+  assert.equal(`{"humidity":40}`, received.iterated, "messages() should apply the client payload format")
+  assert.true(failed, "Invalid JSON payload should fire the error event")
+}
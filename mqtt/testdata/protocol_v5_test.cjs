@@ -0,0 +1,41 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const testTopic = "test/v5"
+var handlerCalled = false
+var endHandlerCalled = false
+
+module.exports = () => {
+  const client = new mqtt.Client({ protocol_version: 5 })
+
+  client.on("connect", async () => {
+    assert.true(client.connected, "Client should be connected after connect event")
+
+    await client.subscribeAsync(testTopic, { qos: 1 })
+    await client.publishAsync(testTopic, "Hello, MQTT v5!", { qos: 1 })
+  })
+
+  client.on("message", async (topic, message) => {
+    const str = String.fromCharCode.apply(null, new Uint8Array(message));
+    assert.equal(testTopic, topic, "Unexpected topic")
+    assert.equal("Hello, MQTT v5!", str, "Unexpected message")
+
+    handlerCalled = true
+
+    await client.unsubscribeAsync(testTopic)
+    await client.endAsync()
+  })
+
+  client.on("end", () => {
+    endHandlerCalled = true
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+
+  assert.true(client.connected, "Client should be connected after connect call")
+}
+
+module.exports.teardown = () => {
+  assert.true(handlerCalled, "Message handler was not called")
+  assert.true(endHandlerCalled, "End handler was not called")
+}
@@ -0,0 +1,48 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const testTopic = "test/batch"
+const received = []
+var syncResult
+var asyncResult
+
+module.exports = () => {
+  const client = new mqtt.Client({ payload_format: "string" })
+
+  client.on("message", (topic, message) => {
+    if (!topic.startsWith(testTopic)) return
+
+    received.push(message)
+
+    if (received.length === 4) client.end()
+  })
+
+  client.on("connect", async () => {
+    await client.subscribeAsync(`${testTopic}/+`, { qos: 1 })
+
+    syncResult = client.publishMany([
+      { topic: `${testTopic}/a`, payload: "a1", qos: 1 },
+      { topic: `${testTopic}/b`, payload: "b1" },
+    ])
+
+    asyncResult = await client.publishManyAsync([
+      { topic: `${testTopic}/a`, payload: "a2", qos: 1 },
+      { topic: `${testTopic}/b`, payload: new Uint8Array([98, 50]), qos: 2 },
+    ])
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+}
+
+module.exports.teardown = () => {
+  for (const result of [syncResult, asyncResult]) {
+    assert.equal(2, result.results.length, "Unexpected number of results")
+    assert.equal(`${testTopic}/a`, result.results[0].topic, "Unexpected result topic")
+    assert.equal(null, result.results[0].error, "Unexpected publish error")
+    assert.equal(null, result.results[1].error, "Unexpected publish error")
+    assert.true(result.duration >= 0, "Unexpected duration")
+  }
+
+  assert.equal(4, received.length, "Unexpected number of messages")
+  assert.true(received.includes("b2"), "Message from Uint8Array payload not received")
+}
@@ -0,0 +1,40 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const testTopic = "test/payload"
+const expected = {
+  [`${testTopic}/bytes`]: "hi",
+  [`${testTopic}/view`]: "hi",
+  [`${testTopic}/object`]: '{"temperature":21.5}',
+  [`${testTopic}/json`]: '"hi"',
+}
+const received = {}
+
+module.exports = () => {
+  const client = new mqtt.Client({ payload_format: "string" })
+
+  client.on("message", (topic, message) => {
+    received[topic] = message
+
+    if (Object.keys(received).length === Object.keys(expected).length) client.end()
+  })
+
+  client.on("connect", async () => {
+    await client.subscribeAsync(`${testTopic}/+`)
+
+    const bytes = new Uint8Array([0, 104, 105, 0])
+
+    await client.publishAsync(`${testTopic}/bytes`, bytes.subarray(1, 3))
+    await client.publishAsync(`${testTopic}/view`, new DataView(bytes.buffer, 1, 2))
+    await client.publishAsync(`${testTopic}/object`, { temperature: 21.5 })
+    await client.publishAsync(`${testTopic}/json`, "hi", { encoding: "json" })
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+}
+
+module.exports.teardown = () => {
+  for (const topic in expected) {
+    assert.equal(expected[topic], received[topic], `Unexpected payload on ${topic}`)
+  }
+}
@@ -0,0 +1,72 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const serviceTopic = "test/service"
+const silentTopic = "nobody/listens"
+
+var replies = []
+var timedOut = false
+
+function responder(version, reply) {
+  const client = new mqtt.Client({ protocol_version: version })
+
+  client.on("message", (topic, message, packet) => {
+    reply(client, message, packet)
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+  client.subscribe(serviceTopic + "/v" + version)
+
+  return client
+}
+
+module.exports = () => {
+  const v5 = responder(5, (client, message, packet) => {
+    const str = String.fromCharCode.apply(null, new Uint8Array(message))
+
+    client.publish(packet.properties.response_topic, str + " pong", {
+      properties: { correlation_data: packet.properties.correlation_data },
+    })
+  })
+
+  // On MQTT 3.1.1 the request is an envelope; echoing it back replies with the request payload.
+  const v4 = responder(4, (client, message) => {
+    const str = String.fromCharCode.apply(null, new Uint8Array(message))
+
+    client.publish(JSON.parse(str).response_topic, str)
+  })
+
+  for (const version of [5, 4]) {
+    const client = new mqtt.Client({ protocol_version: version })
+
+    client.on("connect", async () => {
+      const reply = await client.request(serviceTopic + "/v" + version, "ping", { timeout: 5000 })
+
+      replies.push(String.fromCharCode.apply(null, new Uint8Array(reply)))
+
+      if (version === 5) {
+        try {
+          await client.request(silentTopic, "ping", { timeout: 100 })
+        } catch (e) {
+          timedOut = e.method === "request"
+        }
+      }
+
+      client.end()
+
+      if (replies.length === 2) {
+        v5.end()
+        v4.end()
+      }
+    })
+
+    client.connect(__ENV.MQTT_BROKER_ADDRESS)
+  }
+}
+
+module.exports.teardown = () => {
+  assert.equal(2, replies.length, "Unexpected number of replies")
+  assert.true(replies.includes("ping pong"), "Missing MQTT v5 reply")
+  assert.true(replies.includes("ping"), "Missing MQTT 3.1.1 reply")
+  assert.true(timedOut, "Request without reply should time out")
+}
@@ -0,0 +1,98 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const count = 5
+var received = {}
+
+function track(version) {
+  const topic = `test/sequence/${version}`
+  const client = new mqtt.Client({ protocol_version: version, track_sequence: true })
+  const messages = []
+
+  client.on("connect", async () => {
+    await client.subscribeAsync(topic, { qos: 1 })
+
+    for (let i = 1; i <= count; i++) {
+      await client.publishAsync(topic, `message ${i}`, { qos: 1 })
+    }
+  })
+
+  client.on("message", (t, message) => {
+    messages.push(String.fromCharCode.apply(null, new Uint8Array(message)))
+
+    if (messages.length === count) {
+      assert.equal("message 1", messages[0], "Sequence number not removed from payload")
+
+      received[version] = true
+      client.end()
+    }
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+}
+
+// anomalies receives one message out of order, one twice and misses one.
+// The shared subscription does not get the retained message,
+// so the retained message 2 is only delivered after message 3, by the plain subscription,
+// and once again when subscribing after message 6.
+function anomalies() {
+  const topic = "test/sequence/anomalies"
+  const shared = "$share/sequence/" + topic
+  const client = new mqtt.Client({ protocol_version: 5, track_sequence: true })
+  const expected = 6
+  var messages = 0
+  var arrived
+
+  const all = new Promise((resolve) => {
+    arrived = resolve
+  })
+
+  client.on("connect", async () => {
+    await client.subscribeAsync(topic, { qos: 1 })
+    await client.publishAsync(topic, "message 1", { qos: 1 })
+
+    await client.unsubscribeAsync(topic)
+    await client.publishAsync(topic, "message 2", { qos: 1, retain: true })
+
+    await client.subscribeAsync(shared, { qos: 1 })
+    await client.publishAsync(topic, "message 3", { qos: 1 })
+
+    await client.subscribeAsync(topic, { qos: 1 })
+    await client.publishAsync(topic, "message 4", { qos: 1 })
+
+    await client.unsubscribeAsync(topic)
+    await client.unsubscribeAsync(shared)
+    await client.publishAsync(topic, "message 5", { qos: 1 })
+
+    await client.subscribeAsync(shared, { qos: 1 })
+    await client.publishAsync(topic, "message 6", { qos: 1 })
+
+    await client.subscribeAsync(topic, { qos: 1 })
+
+    await all
+
+    // The missing message 5 is counted as lost on end.
+    received.anomalies = true
+    client.end()
+  })
+
+  client.on("message", () => {
+    if (++messages === expected) {
+      arrived()
+    }
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+}
+
+module.exports = () => {
+  track(4)
+  track(5)
+  anomalies()
+}
+
+module.exports.teardown = () => {
+  assert.true(received[4], "MQTT 3.1.1 messages were not received")
+  assert.true(received[5], "MQTT v5 messages were not received")
+  assert.true(received.anomalies, "Out of order, duplicated and lost messages were not received")
+}
@@ -0,0 +1,49 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const testTopic = "test/shared"
+const sharedFilter = "$share/workers/" + testTopic
+const messageCount = 10
+
+var received = 0
+var rejected = false
+
+module.exports = () => {
+  const workers = [5, 4].map((version) => {
+    const worker = new mqtt.Client({ protocol_version: version })
+
+    worker.on("message", (topic) => {
+      assert.equal(testTopic, topic, "Shared subscription should deliver the real topic")
+
+      if (++received === messageCount) {
+        workers.forEach((w) => w.end())
+      }
+    })
+
+    worker.connect(__ENV.MQTT_BROKER_ADDRESS)
+    worker.subscribe(sharedFilter, { qos: 1 })
+
+    return worker
+  })
+
+  const publisher = new mqtt.Client()
+
+  publisher.connect(__ENV.MQTT_BROKER_ADDRESS)
+
+  for (let i = 0; i < messageCount; i++) {
+    publisher.publish(testTopic, "job " + i, { qos: 1 })
+  }
+
+  publisher.end()
+
+  try {
+    workers[0].subscribe("$share/workers")
+  } catch (e) {
+    rejected = true
+  }
+}
+
+module.exports.teardown = () => {
+  assert.equal(messageCount, received, "Each message should be delivered to exactly one worker")
+  assert.true(rejected, "Subscribing to an invalid shared filter should fail")
+}
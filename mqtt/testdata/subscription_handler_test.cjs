@@ -0,0 +1,53 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+var done = {}
+
+function check(version) {
+  const topic = `test/handler/${version}`
+  const client = new mqtt.Client({ protocol_version: version })
+  const received = { handler: [], event: [] }
+
+  const finish = () => {
+    if (received.handler.length === 1 && received.event.length === 1) {
+      assert.equal(`${topic}/a`, received.handler[0], "Unexpected topic in subscription handler")
+      assert.equal(`${topic}/b`, received.event[0], "Unexpected topic in message event")
+
+      done[version] = true
+      client.end()
+    }
+  }
+
+  client.on("connect", async () => {
+    await client.subscribeAsync(`${topic}/a`, {
+      qos: 1,
+      handler: (t, message, packet) => {
+        assert.equal(1, packet.qos, "Unexpected QoS in subscription handler")
+
+        received.handler.push(t)
+        finish()
+      },
+    })
+    await client.subscribeAsync(`${topic}/b`, { qos: 1 })
+
+    await client.publishAsync(`${topic}/a`, "Hello, handler!", { qos: 1 })
+    await client.publishAsync(`${topic}/b`, "Hello, event!", { qos: 1 })
+  })
+
+  client.on("message", (t) => {
+    received.event.push(t)
+    finish()
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+}
+
+module.exports = () => {
+  check(4)
+  check(5)
+}
+
+module.exports.teardown = () => {
+  assert.true(done[4], "MQTT 3.1.1 subscription handler was not called")
+  assert.true(done[5], "MQTT v5 subscription handler was not called")
+}
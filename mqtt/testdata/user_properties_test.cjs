@@ -0,0 +1,45 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const testTopic = "test/properties"
+var handlerCalled = false
+var rejected = false
+
+module.exports = () => {
+  const client = new mqtt.Client({ protocol_version: 5 })
+
+  client.on("connect", async () => {
+    await client.subscribeAsync(testTopic)
+    await client.publishAsync(testTopic, "Hello, MQTT v5!", { properties: { user: { tenant: "acme", schema: "v2" } } })
+  })
+
+  client.on("message", (topic, message, packet) => {
+    assert.equal(testTopic, topic, "Unexpected topic")
+    assert.equal("acme", packet.properties.user.tenant, "Unexpected tenant user property")
+    assert.equal("v2", packet.properties.user.schema, "Unexpected schema user property")
+
+    handlerCalled = true
+    client.end()
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+
+  const legacy = new mqtt.Client()
+
+  legacy.on("connect", () => {
+    try {
+      legacy.publish(testTopic, "Hello, MQTT!", { properties: { user: { tenant: "acme" } } })
+    } catch (e) {
+      rejected = true
+    }
+
+    legacy.end()
+  })
+
+  legacy.connect(__ENV.MQTT_BROKER_ADDRESS)
+}
+
+module.exports.teardown = () => {
+  assert.true(handlerCalled, "Message handler was not called")
+  assert.true(rejected, "Publishing properties over MQTT 3.1.1 should fail")
+}
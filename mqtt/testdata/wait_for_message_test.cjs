@@ -0,0 +1,62 @@
+const mqtt = require("k6/x/mqtt")
+const assert = require("k6/x/assert")
+
+const testTopic = "test/wait"
+var received = false
+var timedOut = false
+var errorFired = false
+var rejectedWithListener = false
+
+module.exports = () => {
+  const client = new mqtt.Client()
+
+  client.on("connect", async () => {
+    await client.subscribeAsync(`${testTopic}/#`)
+
+    const waiting = client.waitForMessage(`${testTopic}/+/status`, { timeout: 5000 })
+
+    await client.publishAsync(`${testTopic}/device-1/telemetry`, "ignored")
+    await client.publishAsync(`${testTopic}/device-1/status`, "online")
+
+    const message = await waiting
+
+    assert.equal(`${testTopic}/device-1/status`, message.topic, "Unexpected topic")
+    assert.equal("online", String.fromCharCode.apply(null, new Uint8Array(message.payload)), "Unexpected payload")
+    assert.equal(0, message.packet.qos, "Unexpected QoS")
+
+    received = true
+
+    try {
+      await client.waitForMessage(`${testTopic}/never`, { timeout: 50 })
+    } catch (e) {
+      assert.equal("waitForMessage", e.method, "Unexpected error method")
+
+      timedOut = true
+    }
+
+    client.on("error", (e) => {
+      assert.equal("waitForMessage", e.method, "Unexpected error method")
+
+      errorFired = true
+    })
+
+    try {
+      await client.waitForMessage(`${testTopic}/never`, { timeout: 50 })
+    } catch (e) {
+      assert.equal("waitForMessage", e.method, "Unexpected error method")
+
+      rejectedWithListener = true
+    }
+
+    client.end()
+  })
+
+  client.connect(__ENV.MQTT_BROKER_ADDRESS)
+}
+
+module.exports.teardown = () => {
+  assert.true(received, "Matching message was not received")
+  assert.true(timedOut, "Waiting for a message should time out")
+  assert.true(errorFired, "Timeout should fire the error event when it has a listener")
+  assert.true(rejectedWithListener, "Timeout should reject even when the error event has a listener")
+}
@@ -0,0 +1,84 @@
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const sharePrefix = "$share/"
+
+var errInvalidTopicFilter = errors.New("invalid topic filter")
+
+// parseSharedFilter splits a $share/<group>/<filter> shared subscription filter.
+// It returns ok == false for non-shared filters.
+func parseSharedFilter(filter string) (group string, topicFilter string, ok bool, err error) {
+	rest, found := strings.CutPrefix(filter, sharePrefix)
+	if !found {
+		return "", filter, false, nil
+	}
+
+	group, topicFilter, found = strings.Cut(rest, "/")
+	if !found || len(group) == 0 || len(topicFilter) == 0 || strings.ContainsAny(group, "+#") {
+		return "", "", false, fmt.Errorf("%w: expected $share/<group>/<filter>, got %q", errInvalidTopicFilter, filter)
+	}
+
+	return group, topicFilter, true, nil
+}
+
+// validateFilter checks the syntax of a (possibly shared) MQTT topic filter.
+func validateFilter(filter string) error {
+	_, topicFilter, _, err := parseSharedFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	if len(topicFilter) == 0 {
+		return fmt.Errorf("%w: empty filter", errInvalidTopicFilter)
+	}
+
+	levels := strings.Split(topicFilter, "/")
+
+	for i, level := range levels {
+		if level == "#" && i != len(levels)-1 {
+			return fmt.Errorf("%w: # must be the last level, got %q", errInvalidTopicFilter, filter)
+		}
+
+		if level != "#" && level != "+" && strings.ContainsAny(level, "+#") {
+			return fmt.Errorf("%w: wildcards must occupy an entire level, got %q", errInvalidTopicFilter, filter)
+		}
+	}
+
+	return nil
+}
+
+// topicMatches reports whether the topic name matches the MQTT topic filter, including wildcards.
+func topicMatches(filter string, topic string) bool {
+	if _, f, ok, err := parseSharedFilter(filter); ok && err == nil {
+		filter = f
+	}
+
+	// Topics starting with $ are not matched by filters starting with a wildcard.
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+
+		if i >= len(topicLevels) {
+			return false
+		}
+
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+
+	return len(filterLevels) == len(topicLevels)
+}
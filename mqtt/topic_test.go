@@ -0,0 +1,81 @@
+package mqtt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_topicMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		filter string
+		topic  string
+		want   bool
+	}{
+		{"sensors/temp", "sensors/temp", true},
+		{"sensors/temp", "sensors/humidity", false},
+		{"sensors/+", "sensors/temp", true},
+		{"sensors/+", "sensors/temp/1", false},
+		{"sensors/+/1", "sensors/temp/1", true},
+		{"sensors/#", "sensors", true},
+		{"sensors/#", "sensors/temp/1", true},
+		{"#", "sensors/temp", true},
+		{"#", "$SYS/uptime", false},
+		{"+/uptime", "$SYS/uptime", false},
+		{"$SYS/#", "$SYS/uptime", true},
+		{"$share/workers/sensors/+", "sensors/temp", true},
+		{"$share/workers/sensors/+", "devices/temp", false},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, topicMatches(tt.filter, tt.topic), "filter %q, topic %q", tt.filter, tt.topic)
+	}
+}
+
+func Test_parseSharedFilter(t *testing.T) {
+	t.Parallel()
+
+	group, filter, ok, err := parseSharedFilter("$share/workers/jobs/#")
+
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "workers", group)
+	require.Equal(t, "jobs/#", filter)
+
+	_, filter, ok, err = parseSharedFilter("jobs/#")
+
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, "jobs/#", filter)
+
+	for _, invalid := range []string{"$share/workers", "$share//jobs", "$share/work+ers/jobs", "$share/workers/"} {
+		_, _, _, err = parseSharedFilter(invalid)
+
+		require.ErrorIs(t, err, errInvalidTopicFilter, invalid)
+	}
+}
+
+func Test_client_shareGroup(t *testing.T) {
+	t.Parallel()
+
+	c := new(client)
+
+	c.shareGroups.Store("$share/workers/jobs/+", "workers")
+
+	require.Equal(t, "workers", c.shareGroup("jobs/1"))
+	require.Empty(t, c.shareGroup("events/1"))
+}
+
+func Test_validateFilter(t *testing.T) {
+	t.Parallel()
+
+	for _, filter := range []string{"a", "a/b", "+", "#", "a/+/c", "a/#", "+/+/#", "$share/g/a/#"} {
+		require.NoError(t, validateFilter(filter), filter)
+	}
+
+	for _, filter := range []string{"", "a/#/c", "a#", "a/b+", "$share/g", "$share//a"} {
+		require.ErrorIs(t, validateFilter(filter), errInvalidTopicFilter, filter)
+	}
+}